@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/gavv/md-authors/src/cache"
+	"github.com/gavv/md-authors/src/logs"
+)
+
+const cacheUsage = `Usage: %s cache COMMAND [OPTIONS] [ARGS]...
+
+COMMANDS:
+  list                  print cached entries
+  show KEY...           print cached entries with given keys
+  forget LOGIN|EMAIL... remove all entries related to given logins or emails
+  prune                 remove entries older than --older-than
+  export [FILE]         write cache to FILE or stdout
+  import [FILE]         merge cache from FILE or stdin
+
+OPTIONS:
+`
+
+const cacheUsageFooter = `
+Cache keys have form "FORGE:KIND:SUBJECT", e.g. "github:n2l:Ford Prefect".
+For GitHub Enterprise, FORGE is "github/HOSTNAME[:PORT]".
+
+Supported KINDs (for --kind option):
+  n2l           git name to github login
+  e2l           git email to github login
+  l2n           github login to name
+  l2e           github login to email
+  l2p           github login to user profile
+  cc            commits of login in project
+  pc            commits of login in project's pull requests
+  ec            commits of login in public events
+  c2l           commit in project to github login
+
+DURATION (for --older-than option) is a number followed by unit, one of:
+s, m, h, d (days), w (weeks). Entries created by old versions of the tool
+have no timestamp and are considered infinitely old.
+
+EXAMPLES:
+  md-authors cache list --login Ix
+  md-authors cache forget ford@betelgeuse7.sid
+  md-authors cache prune --older-than 30d
+  md-authors cache export > cache.json
+`
+
+// Parsed cache key.
+type cacheKey struct {
+	forge   string
+	kind    string
+	project string
+	subject string
+}
+
+func parseCacheKey(key string) cacheKey {
+	var k cacheKey
+
+	forge, rest, _ := strings.Cut(key, ":")
+
+	// GitHub Enterprise namespace may include port, e.g. "github/HOST:PORT"
+	if strings.Contains(forge, "/") {
+		if port, tail, ok := strings.Cut(rest, ":"); ok {
+			if _, err := strconv.Atoi(port); err == nil {
+				forge, rest = forge+":"+port, tail
+			}
+		}
+	}
+
+	kind, subject, ok := strings.Cut(rest, ":")
+	if !ok {
+		k.subject = key
+		return k
+	}
+
+	k.forge = forge
+	k.kind = kind
+	k.subject = subject
+
+	switch k.kind {
+	case "cc", "pc", "c2l":
+		// project is "owner/repo" and can't contain colons
+		if i := strings.Index(k.subject, ":"); i >= 0 {
+			k.project = k.subject[:i]
+			k.subject = k.subject[i+1:]
+		} else {
+			k.project = k.subject
+			k.subject = ""
+		}
+	}
+
+	return k
+}
+
+// Check whether entry is related to given login or email.
+func cacheEntryRelated(e cache.Entry, who string) bool {
+	k := parseCacheKey(e.Key)
+
+	if strings.EqualFold(k.subject, who) {
+		return true
+	}
+
+	switch k.kind {
+	case "n2l", "e2l", "l2e", "c2l":
+		// value is login or email
+		return strings.EqualFold(e.Value, who)
+	}
+
+	return false
+}
+
+func runCache(args []string) {
+	fset := pflag.NewFlagSet("md-authors cache", pflag.ContinueOnError)
+
+	fset.SortFlags = false
+	fset.Usage = func() {
+		fmt.Fprintf(os.Stderr, cacheUsage, "md-authors")
+		fset.PrintDefaults()
+		fmt.Fprint(os.Stderr, cacheUsageFooter)
+	}
+
+	project := fset.StringP("project", "p", "", "list: only entries of given project")
+	login := fset.StringP("login", "l", "", "list: only entries related to given login or email")
+	kind := fset.StringP("kind", "k", "", "list: only entries of given kind")
+	raw := fset.BoolP("raw", "R", false, "list, show: don't decode values")
+	olderThan := fset.StringP("older-than", "o", "", "prune: max age of entries to keep")
+	cachePath := fset.StringP("cache", "C", "", "path to cache file")
+	fset.BoolVarP(&logs.EnableDebug, "debug", "d", false, "enable debug logging")
+	help := fset.BoolP("help", "h", false, "print this message and exit")
+
+	err := fset.Parse(args)
+	if err != nil {
+		os.Exit(2)
+	}
+	if *help {
+		fset.Usage()
+		os.Exit(0)
+	}
+
+	if fset.NArg() < 1 {
+		logs.Fatalf("no cache command specified")
+	}
+
+	setupCache(*cachePath, false)
+
+	command, cmdArgs := fset.Arg(0), fset.Args()[1:]
+
+	switch command {
+	case "list":
+		if len(cmdArgs) != 0 {
+			logs.Fatalf("unexpected arguments for 'cache list'")
+		}
+		for _, e := range cache.DiskEntries() {
+			k := parseCacheKey(e.Key)
+			if *project != "" && !strings.EqualFold(k.project, *project) {
+				continue
+			}
+			if *login != "" && !cacheEntryRelated(e, *login) {
+				continue
+			}
+			if *kind != "" && k.kind != *kind {
+				continue
+			}
+			printCacheEntry(e, *raw)
+		}
+
+	case "show":
+		if len(cmdArgs) == 0 {
+			logs.Fatalf("no keys specified for 'cache show'")
+		}
+		entries := cache.DiskEntries()
+		for _, key := range cmdArgs {
+			found := false
+			for _, e := range entries {
+				if e.Key == key {
+					printCacheEntry(e, *raw)
+					found = true
+				}
+			}
+			if !found {
+				logs.Fatalf("key %q not found in cache", key)
+			}
+		}
+
+	case "forget":
+		if len(cmdArgs) == 0 {
+			logs.Fatalf("no logins or emails specified for 'cache forget'")
+		}
+		n := cacheForget(cmdArgs)
+		logs.Infof("removed %d cache entries", n)
+
+	case "prune":
+		if len(cmdArgs) != 0 {
+			logs.Fatalf("unexpected arguments for 'cache prune'")
+		}
+		if *olderThan == "" {
+			logs.Fatalf("--older-than is required for 'cache prune'")
+		}
+		age, err := parseAge(*olderThan)
+		if err != nil {
+			logs.Fatalf("--older-than=%s not recognized", *olderThan)
+		}
+		n := cachePrune(time.Now().Add(-age))
+		logs.Infof("removed %d cache entries", n)
+
+	case "export":
+		if len(cmdArgs) > 1 {
+			logs.Fatalf("unexpected arguments for 'cache export'")
+		}
+		b := cache.MarshalEntries(cache.DiskEntries())
+		b = append(b, '\n')
+		if len(cmdArgs) == 0 {
+			os.Stdout.Write(b)
+		} else if err := os.WriteFile(cmdArgs[0], b, 0644); err != nil {
+			logs.Fatalf("can't write %q: %s", cmdArgs[0], err)
+		}
+
+	case "import":
+		if len(cmdArgs) > 1 {
+			logs.Fatalf("unexpected arguments for 'cache import'")
+		}
+		var (
+			b   []byte
+			err error
+		)
+		if len(cmdArgs) == 0 {
+			b, err = io.ReadAll(os.Stdin)
+		} else {
+			b, err = os.ReadFile(cmdArgs[0])
+		}
+		if err != nil {
+			logs.Fatalf("can't read cache: %s", err)
+		}
+		entries, err := cache.UnmarshalEntries(b)
+		if err != nil {
+			logs.Fatalf("can't parse cache: %s", err)
+		}
+		cache.DiskImport(entries)
+		logs.Infof("imported %d cache entries", len(entries))
+
+	default:
+		logs.Fatalf("cache command %q not recognized", command)
+	}
+}
+
+// Remove entries related to any of given logins or emails.
+// Returns number of removed entries.
+func cacheForget(who []string) int {
+	var keys []string
+
+	for _, e := range cache.DiskEntries() {
+		for _, w := range who {
+			if cacheEntryRelated(e, w) {
+				keys = append(keys, e.Key)
+				break
+			}
+		}
+	}
+
+	cache.DiskDelete(keys)
+
+	return len(keys)
+}
+
+// Remove entries created before deadline, including entries
+// without timestamp. Returns number of removed entries.
+func cachePrune(deadline time.Time) int {
+	var keys []string
+
+	for _, e := range cache.DiskEntries() {
+		if e.Time.Before(deadline) {
+			keys = append(keys, e.Key)
+		}
+	}
+
+	cache.DiskDelete(keys)
+
+	return len(keys)
+}
+
+func printCacheEntry(e cache.Entry, raw bool) {
+	value := e.Value
+	if !raw {
+		if decoded, ok := cache.Decode(value); ok {
+			value = decoded
+		}
+	}
+
+	date := "-"
+	if !e.Time.IsZero() {
+		date = e.Time.Local().Format("2006-01-02")
+	}
+
+	fmt.Printf("%s\t%s\t%s\n", date, e.Key, value)
+}
+
+// Parse duration like "90m", "12h", "30d", "2w".
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var unit time.Duration
+	switch s[len(s)-1] {
+	case 's':
+		unit = time.Second
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("missing unit")
+	}
+
+	n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bad number")
+	}
+
+	return time.Duration(n * float64(unit)), nil
+}
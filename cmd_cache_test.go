@@ -0,0 +1,239 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gavv/md-authors/src/cache"
+)
+
+func TestParseCacheKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want cacheKey
+	}{
+		{
+			"github:n2l:Ford Prefect",
+			cacheKey{forge: "github", kind: "n2l", subject: "Ford Prefect"},
+		},
+		{
+			"github/ghe.example.com:e2l:ford@betelgeuse7.sid",
+			cacheKey{forge: "github/ghe.example.com", kind: "e2l", subject: "ford@betelgeuse7.sid"},
+		},
+		{
+			// port is part of namespace
+			"github/ghe.example.com:8443:l2n:Ix",
+			cacheKey{forge: "github/ghe.example.com:8443", kind: "l2n", subject: "Ix"},
+		},
+		{
+			"github/ghe.example.com:8443:cc:magrathea/earth:Ix",
+			cacheKey{forge: "github/ghe.example.com:8443", kind: "cc", project: "magrathea/earth",
+				subject: "Ix"},
+		},
+		{
+			"github:c2l:magrathea/earth:1111111111111111111111111111111111111111",
+			cacheKey{forge: "github", kind: "c2l", project: "magrathea/earth",
+				subject: "1111111111111111111111111111111111111111"},
+		},
+		{
+			"github:cc:magrathea/earth:Ix",
+			cacheKey{forge: "github", kind: "cc", project: "magrathea/earth", subject: "Ix"},
+		},
+		{
+			"github:pc:magrathea/earth",
+			cacheKey{forge: "github", kind: "pc", project: "magrathea/earth"},
+		},
+		{
+			// colons in subject are kept for kinds without project
+			"github:n2l:Ford: Prefect",
+			cacheKey{forge: "github", kind: "n2l", subject: "Ford: Prefect"},
+		},
+		{
+			"github:l2n",
+			cacheKey{subject: "github:l2n"},
+		},
+		{
+			"github/ghe.example.com:8443:l2n",
+			cacheKey{subject: "github/ghe.example.com:8443:l2n"},
+		},
+		{
+			"",
+			cacheKey{},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := parseCacheKey(tt.key); got != tt.want {
+			t.Errorf("parseCacheKey(%q):\n got: %+v\nwant: %+v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"10s", 10 * time.Second},
+		{"90m", 90 * time.Minute},
+		{"1.5h", 90 * time.Minute},
+		{"12h", 12 * time.Hour},
+		{"30d", 30 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"0d", 0},
+	}
+
+	for _, tt := range tests {
+		got, err := parseAge(tt.input)
+		if err != nil {
+			t.Errorf("parseAge(%q): unexpected error: %s", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAge(%q): got %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "d", "30", "30y", "-1d", "xd", "1 d"} {
+		if _, err := parseAge(input); err == nil {
+			t.Errorf("parseAge(%q): expected error", input)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	// keep tests away from user's cache file
+	dir, err := os.MkdirTemp("", "mdauthors-test-")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CACHE_HOME", dir)
+	os.Setenv("HOME", dir)
+
+	code := m.Run()
+
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// Replace contents of cache with given entries.
+func setupCacheEntries(t *testing.T, entries []cache.Entry) {
+	t.Helper()
+
+	cache.DiskDelete(cacheKeys())
+	cache.DiskImport(entries)
+}
+
+func cacheKeys() []string {
+	var keys []string
+	for _, e := range cache.DiskEntries() {
+		keys = append(keys, e.Key)
+	}
+	return keys
+}
+
+func TestCacheForget(t *testing.T) {
+	now := time.Now()
+
+	entries := []cache.Entry{
+		{Key: "github:n2l:Ford Prefect", Value: "Ix", Time: now},
+		{Key: "github:e2l:ford@betelgeuse7.sid", Value: "Ix", Time: now},
+		{Key: "github:l2n:Ix", Value: "Ford Prefect", Time: now},
+		{Key: "github:l2e:Ix", Value: "ford@betelgeuse7.sid", Time: now},
+		{Key: "github:cc:magrathea/earth:Ix", Value: "[]", Time: now},
+		{Key: "github:c2l:magrathea/earth:1111", Value: "Ix", Time: now},
+		{Key: "github:l2n:zaphod", Value: "Zaphod Beeblebrox", Time: now},
+		{Key: "github:e2l:zaphod@heartofgold.sid", Value: "zaphod", Time: now},
+	}
+
+	tests := []struct {
+		name string
+		who  []string
+		want []string
+	}{
+		{
+			name: "login",
+			who:  []string{"ix"},
+			want: []string{
+				"github:e2l:zaphod@heartofgold.sid",
+				"github:l2n:zaphod",
+			},
+		},
+		{
+			name: "email",
+			who:  []string{"zaphod@heartofgold.sid"},
+			want: []string{
+				"github:c2l:magrathea/earth:1111",
+				"github:cc:magrathea/earth:Ix",
+				"github:e2l:ford@betelgeuse7.sid",
+				"github:l2e:Ix",
+				"github:l2n:Ix",
+				"github:l2n:zaphod",
+				"github:n2l:Ford Prefect",
+			},
+		},
+		{
+			name: "multiple",
+			who:  []string{"Ix", "zaphod"},
+			want: nil,
+		},
+		{
+			name: "unknown",
+			who:  []string{"marvin"},
+			want: []string{
+				"github:c2l:magrathea/earth:1111",
+				"github:cc:magrathea/earth:Ix",
+				"github:e2l:ford@betelgeuse7.sid",
+				"github:e2l:zaphod@heartofgold.sid",
+				"github:l2e:Ix",
+				"github:l2n:Ix",
+				"github:l2n:zaphod",
+				"github:n2l:Ford Prefect",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCacheEntries(t, entries)
+
+			n := cacheForget(tt.who)
+
+			got := cacheKeys()
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("unexpected entries:\n got: %q\nwant: %q", got, tt.want)
+			}
+			if n != len(entries)-len(tt.want) {
+				t.Errorf("unexpected count: got %d, want %d", n, len(entries)-len(tt.want))
+			}
+		})
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	now := time.Now()
+
+	setupCacheEntries(t, []cache.Entry{
+		{Key: "github:l2n:Ix", Value: "Ford Prefect", Time: now.Add(-40 * 24 * time.Hour)},
+		{Key: "github:l2n:trillian", Value: "Tricia McMillan", Time: now.Add(-10 * 24 * time.Hour)},
+		{Key: "github:l2n:zaphod", Value: "Zaphod Beeblebrox", Time: now},
+		// created by old version, without timestamp
+		{Key: "github:l2n:marvin", Value: "Marvin"},
+	})
+
+	age, err := parseAge("30d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := cachePrune(now.Add(-age)); n != 2 {
+		t.Errorf("unexpected count: got %d, want 2", n)
+	}
+
+	want := []string{"github:l2n:trillian", "github:l2n:zaphod"}
+	if got := cacheKeys(); !slices.Equal(got, want) {
+		t.Errorf("unexpected entries:\n got: %q\nwant: %q", got, want)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 
@@ -14,24 +15,20 @@ import (
 	"github.com/gavv/md-authors/src/logs"
 )
 
-var builtinFormats = map[string]string{
-	// Example:
-	//  1. Ford Prefect `@Ix`
-	"modern": "{index}. {name} `{login?}`\\n",
-
-	// Example:
-	//  - Ford Prefect `Ix` (<ford@betelgeuse7.sid>)
-	"classic": "- {name} `{login?}` (<{email|profile?}>)\\n",
-}
-
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		runCache(os.Args[2:])
+		return
+	}
+
 	var conf defs.Config
 
 	fset := pflag.NewFlagSet("md-authors", pflag.ContinueOnError)
 
 	fset.SortFlags = false
 	fset.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] [FILES]...\n\n", fset.Name())
+		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] [FILES]...\n", fset.Name())
+		fmt.Fprintf(os.Stderr, "       %s cache COMMAND [OPTIONS] [ARGS]...\n\n", fset.Name())
 		fmt.Fprintf(os.Stderr, "OPTIONS:\n")
 		fset.PrintDefaults()
 		fmt.Fprintf(os.Stderr, `
@@ -39,6 +36,52 @@ When one or more FILES specified, the tool finds <!-- authors --> block
 in each file and replaces its contents with the new up-to-date list.
 When --pipe is specified, the tool instead writes authors list to stdout.
 
+If FILE is named .all-contributorsrc, it is created or updated instead:
+"contributors" list is rebuilt from authors with github login, or new
+authors are appended to it if --append is specified. Existing entries
+are matched by login and are kept as is. If .all-contributorsrc exists
+in the directory of FILE, contribution types of authors are read from
+it and are available via {roles} field.
+
+Similarly, if FILE is named CITATION.cff or .zenodo.json, "authors"
+or "creators" list in it is created or updated. Existing entries are
+matched with authors by login, email, or name, and are kept as is,
+including fields like orcid. Without --append, the list is rebuilt in
+the order of authors, and entries of unknown authors are removed.
+
+If FILE is named package.json, Cargo.toml, or pyproject.toml, the
+"contributors" or "authors" list in it is regenerated, or new authors
+are appended to it if --append is specified.
+
+If FILE is debian/copyright, blocks between "# authors" and
+"# endauthors" comment lines are updated instead, using dep5 format
+by default, e.g. "2019-2024 Name <email>".
+
+Block markers depend on markup, which is detected by file extension,
+or can be set via --markers:
+  markdown      <!-- authors --> / <!-- endauthors -->
+  html          <!-- authors --> / <!-- endauthors -->
+  rst           .. authors / .. endauthors
+  asciidoc      // authors / // endauthors
+  org           # authors / # endauthors
+  debian        # authors / # endauthors
+If --format and --template are not specified, builtin format named
+after markup is used ("modern" for markdown, "dep5" for debian).
+
+Instead of "authors", other marker names can be used via --marker,
+which is a regexp matching the whole name, e.g. --marker=contributors
+for <!-- contributors --> / <!-- endcontributors -->. It can be
+repeated to handle several kinds of blocks. Begin marker can have
+attributes that override options for this block:
+  escape=MODE   same as --escape
+  format=SPEC   same as --format, spec or builtin name
+  sort=ORDER    same as --sort
+  append=BOOL   same as --append, true or false
+  ignore=LIST   added to --ignore
+For example: <!-- authors format=classic sort=name -->. --marker can
+also specify default attributes for its blocks after a space, e.g.
+--marker="contributors format=classic".
+
 If --append is specified, the old contents is kept unaffected, and only
 new authors missing in old contents are appended to the end. In case of
 --pipe, old contents is read from stdin.
@@ -60,26 +103,84 @@ FIELDS SYNTAX:
   {foo|bar}     same, but if 'foo' is empty, uses 'bar'
   {foo|bar?}    same, but if 'foo' and 'bar' are both empty, removes
                 adjustent non-whitespace characters
+  {foo:mod}     same, but value is transformed by modifier 'mod';
+                modifiers may be chained, e.g. {foo|bar:upper:pad=9?}
+
+MODIFIERS LIST:
+  upper         convert to upper case
+  lower         convert to lower case
+  pad=N         pad with spaces on the right to N characters
+  lpad=N        pad with spaces on the left to N characters
+  trunc=N       truncate to N characters
+  obfuscate     replace @ and . with " at " and " dot "
+  "LAYOUT"      reformat date using Go time layout, e.g. "Jan 2006"
 
 FIELDS LIST:
   index         entry number
   date          date of first contribution
+  lastdate      date of last contribution
+  years         years of contribution, e.g. "2019-2024"
   name          full name
   email         email address
   login         github login
   profile       github profile url
+  avatar        github avatar url (see --avatar-size)
+  company       company from github profile
+  website       website from github profile
+  location      location from github profile
+  twitter       twitter username from github profile
+  bio           bio from github profile
+  roles         contribution types from .all-contributorsrc
+
+FORMAT SPEC can be also a NAME of predefined spec (see below), or
+a Go template prefixed with "tmpl:", e.g.:
+ tmpl:* {{.Name | upper}}{{with .Login}} @{{.}}{{end}}
 
-FORMAT SPEC can be also a NAME of predefined spec:
+Longer templates can be read from file via --template option.
+Template is executed for every author, with fields Index, Date,
+LastDate, Name, Email, Login, Profile, Avatar, Company, Website,
+Location, Twitter, Bio, Roles. Optional "header" and "footer"
+templates are executed before and after entries. If "groupby"
+template is defined, "group" template is executed when its output
+changes. If template defines "block" template, it is executed once
+instead, with list of authors. Available functions: lower, upper,
+truncate N, date LAYOUT, mdescape, escape, html, mod A B,
+avatarSize, avatarColumns.
+
+Predefined specs:
 `)
 		var specs []string
-		for k := range builtinFormats {
+		for k := range gen.BuiltinFormats {
 			specs = append(specs, k)
 		}
 		sort.Strings(specs)
 		for _, k := range specs {
-			fmt.Fprintf(os.Stderr, "  %s\n      \"%s\"\n", k, builtinFormats[k])
+			spec, isTemplate := strings.CutPrefix(gen.BuiltinFormats[k], "tmpl:")
+			if isTemplate {
+				spec = strings.TrimSuffix(spec, "\n")
+				spec = strings.ReplaceAll(spec, "\n", "\n      ")
+				fmt.Fprintf(os.Stderr, "  %s (template)\n      %s\n", k, spec)
+			} else {
+				fmt.Fprintf(os.Stderr, "  %s\n      \"%s\"\n", k, spec)
+			}
 		}
 		fmt.Fprintf(os.Stderr, `
+Field values are escaped according to --escape option. In markdown
+mode, special characters like * or _ are escaped with backslash,
+except inside code spans (backticks are replaced, or the span is
+widened if the field is its whole content), <autolinks> (only spaces
+and angle brackets are percent-encoded), and <tag attr=...> (html
+escaping is used). In html mode, html escaping is used everywhere.
+In rst mode, markup characters are escaped with backslash, except
+inside inline literals. In asciidoc mode, values with markup
+characters are wrapped into passthrough. In org mode, zero width
+space is inserted after markup characters, except inside =verbatim=.
+Default is markdown for files (or the mode of file's markup, see
+--markers) and none for --pipe. Escaping can be also set per block,
+e.g. <!-- authors escape=none -->. Templates are not escaped
+automatically; use escape function. Modifiers pad, lpad, and trunc
+measure escaped values.
+
 Supported SORT orders (for --sort option):
   date          by first contribution, oldest first
   name          by name, alphabetically
@@ -97,13 +198,34 @@ collect additional or missing fields.
 PROJECT (for --project option) defines project name. For github
 it has form "user/repo". By default it is auto-detected.
 
+For GitHub Enterprise Server, use --github-host option. API and web
+URLs are derived from it, but can be also set explicitly.
+
+GitHub requests are authenticated using token from --token option,
+or $GITHUB_TOKEN, or $GH_TOKEN environment variable. If there is no
+token, "gh" tool is used if it's available. Otherwise, anonymous
+requests are made, which have very low rate limits.
+
+Queried data is cached on disk, by default in ~/.cache/mdauthors.json.
+Use --cache to keep cache in another file, e.g. committed to the repo.
+Run "md-authors cache --help" for commands to inspect and manage it.
+
 EXAMPLES:
   md-authors -f modern -a AUTHORS.md
   md-authors --pipe --format "{name} {email}"
 `)
 	}
 
-	fset.StringVarP(&conf.Format, "format", "f", "modern", "format spec")
+	fset.StringVarP(&conf.Format, "format", "f", "",
+		"format spec (default depends on markup, \"modern\" for markdown)")
+	templatePath := fset.StringP("template", "T", "",
+		"path to go template file (instead of --format)")
+	fset.StringVarP(&conf.Escape, "escape", "e", "auto",
+		"escaping of field values: auto, markdown, html, rst, asciidoc, org, none")
+	fset.StringVar(&conf.Markers, "markers", "auto",
+		"markup of files: auto (by extension), "+strings.Join(gen.MarkupNames(), ", "))
+	fset.StringArrayVar(&conf.MarkerNames, "marker", nil,
+		"block marker name regexp with optional attributes (default \"authors\")")
 	fset.StringVarP(&conf.Sort, "sort", "s", "date", "sort order: date, name")
 	fset.BoolVarP(&conf.Append, "append", "a", false,
 		"append to list instead of replacing")
@@ -113,7 +235,23 @@ EXAMPLES:
 		"comma-separated list of emails, names, and logins to ignore")
 	fset.StringVarP(&conf.Project, "project", "p", "", "github project")
 	fset.BoolVarP(&conf.NoProject, "no-project", "N", false, "don't query github project")
+	fset.StringVarP(&conf.Token, "token", "t", "",
+		"github token (default $GITHUB_TOKEN or $GH_TOKEN)")
+	fset.StringVar(&conf.GithubHost, "github-host", "github.com",
+		"github hostname, for GitHub Enterprise")
+	fset.StringVar(&conf.GithubAPI, "github-api", "",
+		"github api url (default derived from --github-host)")
+	fset.StringVar(&conf.GithubWeb, "github-web", "",
+		"github web url (default derived from --github-host)")
+	fset.StringSliceVar(&conf.GithubRemotes, "github-remotes", nil,
+		"comma-separated list of git remote hosts (default --github-host)")
+	fset.IntVarP(&conf.Jobs, "jobs", "j", 4, "number of concurrent github lookups")
+	fset.IntVar(&conf.AvatarSize, "avatar-size", 64, "size of avatars in pixels")
+	fset.IntVar(&conf.AvatarColumns, "avatar-columns", 8,
+		"number of avatars per row in avatars format")
 	fset.BoolVarP(&cache.Refresh, "refresh", "r", false, "refresh cached data")
+	cachePath := fset.StringP("cache", "C", "", "path to cache file")
+	noCache := fset.Bool("no-cache", false, "don't read or write cache file")
 	fset.BoolVarP(&logs.EnableDebug, "debug", "d", false, "enable debug logging")
 	help := fset.BoolP("help", "h", false, "print this message and exit")
 
@@ -128,15 +266,49 @@ EXAMPLES:
 
 	conf.Ignore = strings.Split(*ignore, ",")
 
+	setupCache(*cachePath, *noCache)
+
+	if conf.Jobs < 1 {
+		logs.Fatalf("--jobs=%d should be positive", conf.Jobs)
+	}
+
+	if conf.AvatarSize < 1 {
+		logs.Fatalf("--avatar-size=%d should be positive", conf.AvatarSize)
+	}
+
+	if conf.AvatarColumns < 1 {
+		logs.Fatalf("--avatar-columns=%d should be positive", conf.AvatarColumns)
+	}
+
 	switch conf.Sort {
 	case "date", "name":
 	default:
 		logs.Fatalf("--sort=%s not recognized", conf.Sort)
 	}
 
-	if !strings.Contains(conf.Format, "{") {
-		f, ok := builtinFormats[conf.Format]
-		if !ok {
+	switch conf.Escape {
+	case "auto", "markdown", "html", "rst", "asciidoc", "org", "none":
+	default:
+		logs.Fatalf("--escape=%s not recognized", conf.Escape)
+	}
+
+	if conf.Markers != "auto" && !slices.Contains(gen.MarkupNames(), conf.Markers) {
+		logs.Fatalf("--markers=%s not recognized", conf.Markers)
+	}
+
+	if *templatePath != "" {
+		if fset.Changed("format") {
+			logs.Fatalf("can't specify --format and --template at the same time")
+		}
+
+		b, err := os.ReadFile(*templatePath)
+		if err != nil {
+			logs.Fatalf("can't read template: %s", err)
+		}
+		conf.Template = string(b)
+	} else if conf.Format != "" {
+		f, err := gen.ResolveFormat(conf.Format)
+		if err != nil {
 			logs.Fatalf("--format=%s not recognized", conf.Format)
 		}
 		conf.Format = f
@@ -148,6 +320,7 @@ EXAMPLES:
 		}
 
 		if err := gen.ProcessPipe(conf); err != nil {
+			cache.Flush()
 			logs.Fatalf("%s", err)
 		}
 	} else {
@@ -157,8 +330,20 @@ EXAMPLES:
 
 		for _, f := range fset.Args() {
 			if err := gen.ProcessFile(f, conf); err != nil {
+				cache.Flush()
 				logs.Fatalf("%s", err)
 			}
 		}
 	}
+
+	cache.Flush()
+}
+
+func setupCache(path string, disable bool) {
+	switch {
+	case disable:
+		cache.SetStore(cache.NewMemoryStore())
+	case path != "":
+		cache.SetStore(cache.NewFileStore(path))
+	}
 }
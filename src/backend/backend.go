@@ -1,6 +1,8 @@
 package backend
 
 import (
+	"sync"
+
 	"github.com/gavv/md-authors/src/defs"
 )
 
@@ -19,3 +21,63 @@ func PopulateAuthor(author defs.Author, conf defs.Config) (defs.Author, error) {
 	// for now, only github is supported
 	return githubPopulate(author, conf)
 }
+
+// Get project name, either from config or auto-detected.
+func ProjectName(conf defs.Config) string {
+	if conf.Project != "" || conf.NoProject {
+		return conf.Project
+	}
+
+	githubInit(conf)
+
+	return githubProject()
+}
+
+// Populate extra fields of multiple authors concurrently.
+// Uses up to conf.Jobs workers. Result has the same order as input.
+func PopulateAuthors(authors []defs.Author, conf defs.Config) ([]defs.Author, error) {
+	if !conf.NoProject {
+		githubInit(conf)
+
+		// resolve as much as possible in batch mode, before
+		// falling back to per-author lookups
+		githubResolveCommits(authors, conf)
+	}
+
+	jobs := conf.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	var (
+		result = make([]defs.Author, len(authors))
+		errs   = make([]error, len(authors))
+		queue  = make(chan int)
+		wg     sync.WaitGroup
+	)
+
+	for range min(jobs, len(authors)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range queue {
+				result[n], errs[n] = PopulateAuthor(authors[n], conf)
+			}
+		}()
+	}
+
+	for n := range authors {
+		queue <- n
+	}
+	close(queue)
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
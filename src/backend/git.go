@@ -11,7 +11,7 @@ import (
 )
 
 func gitCollect(conf defs.Config) ([]defs.Author, error) {
-	cmdArgs := []string{"git", "log", "--format=%as;%aN;%aE", "--reverse"}
+	cmdArgs := []string{"git", "log", "--format=%H;%as;%aN;%aE", "--reverse"}
 
 	logs.Debugf("running: %s", strings.Join(cmdArgs, " "))
 
@@ -22,24 +22,31 @@ func gitCollect(conf defs.Config) ([]defs.Author, error) {
 	}
 
 	authors := []defs.Author{}
-	seen := make(map[string]struct{})
+	seen := make(map[string]int)
 
 	scanner := bufio.NewScanner(strings.NewReader(string(out)))
 	for scanner.Scan() {
 		split := strings.Split(scanner.Text(), ";")
 		author := defs.Author{
-			Name:  split[1],
-			Email: split[2],
-			Date:  split[0],
+			Name:     split[2],
+			Email:    split[3],
+			Date:     split[1],
+			LastDate: split[1],
+			Commit:   split[0],
 		}
-		if _, ok := seen[author.Name]; ok {
-			continue
+		idx, ok := seen[author.Name]
+		if !ok {
+			idx, ok = seen[author.Email]
 		}
-		if _, ok := seen[author.Email]; ok {
+		if ok {
+			// author dates are not necessarily monotonic
+			if author.Date > authors[idx].LastDate {
+				authors[idx].LastDate = author.Date
+			}
 			continue
 		}
-		seen[author.Name] = struct{}{}
-		seen[author.Email] = struct{}{}
+		seen[author.Name] = len(authors)
+		seen[author.Email] = len(authors)
 		authors = append(authors, author)
 	}
 
@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
 	"os/exec"
 	"regexp"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/Jeffail/gabs/v2"
 	"github.com/gofri/go-github-pagination/githubpagination"
@@ -23,11 +28,160 @@ import (
 )
 
 var (
-	noreplyRx = regexp.MustCompile(`^([0-9]+\+)?([^@]+)@users\.noreply\.github\.com$`)
+	noreplyRx = githubNoreplyRx("github.com")
 	spaceRx   = regexp.MustCompile(`\s`)
 )
 
+// Authentication method for GitHub requests.
+type githubAuthMode int
+
+const (
+	// anonymous HTTP requests
+	githubAuthNone githubAuthMode = iota
+	// HTTP requests with bearer token
+	githubAuthToken
+	// requests via 'gh' tool, which uses its own credentials
+	githubAuthGh
+)
+
+// GitHub endpoints, credentials, and clients.
+type githubConfig struct {
+	// hostname, passed to gh
+	host string
+	// namespace of cache keys
+	cacheNs string
+	// hosts recognized in git remotes
+	remoteHosts []string
+	// base urls
+	apiURL     string
+	graphqlURL string
+	webURL     string
+	// credentials
+	auth  githubAuthMode
+	token string
+	// http clients, with and without pagination
+	client          *http.Client
+	paginatedClient *http.Client
+}
+
+var (
+	githubConf githubConfig
+	githubOnce sync.Once
+)
+
+// Transport used by github http clients.
+// Can be replaced in tests.
+var githubTransport http.RoundTripper = http.DefaultTransport
+
+func githubNoreplyRx(host string) *regexp.Regexp {
+	// noreply emails use hostname without port
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	return regexp.MustCompile(
+		`^([0-9]+\+)?([^@]+)@users\.noreply\.` + regexp.QuoteMeta(host) + `$`)
+}
+
+func githubInit(conf defs.Config) {
+	githubOnce.Do(func() {
+		githubConfigure(conf)
+	})
+}
+
+// Setup endpoints and clients, and select authentication method.
+// Explicitly provided token has highest priority, then 'gh' tool,
+// and then anonymous requests.
+func githubConfigure(conf defs.Config) {
+	// defaults are for github.com
+	githubConf = githubConfig{
+		host:        "github.com",
+		cacheNs:     "github",
+		remoteHosts: []string{"github.com"},
+		apiURL:      "https://api.github.com",
+		graphqlURL:  "https://api.github.com/graphql",
+		webURL:      "https://github.com",
+	}
+	noreplyRx = githubNoreplyRx(githubConf.host)
+
+	if conf.GithubHost != "" && conf.GithubHost != githubConf.host {
+		host := conf.GithubHost
+
+		// GitHub Enterprise Server layout
+		githubConf.host = host
+		githubConf.cacheNs = "github/" + host
+		githubConf.remoteHosts = []string{host}
+		githubConf.apiURL = "https://" + host + "/api/v3"
+		githubConf.graphqlURL = "https://" + host + "/api/graphql"
+		githubConf.webURL = "https://" + host
+
+		noreplyRx = githubNoreplyRx(host)
+	}
+	if conf.GithubAPI != "" {
+		githubConf.apiURL = strings.TrimSuffix(conf.GithubAPI, "/")
+		githubConf.graphqlURL =
+			strings.TrimSuffix(githubConf.apiURL, "/v3") + "/graphql"
+	}
+	if conf.GithubWeb != "" {
+		githubConf.webURL = strings.TrimSuffix(conf.GithubWeb, "/")
+	}
+	if len(conf.GithubRemotes) != 0 {
+		githubConf.remoteHosts = conf.GithubRemotes
+	}
+
+	logs.Debugf("github host: %s, api: %s, web: %s",
+		githubConf.host, githubConf.apiURL, githubConf.webURL)
+
+	// rate limiter is shared by all clients and workers, so that when
+	// secondary rate limit is hit, all of them wait
+	rateLimiter, _ := github_ratelimit.NewRateLimitWaiter(githubTransport)
+
+	githubConf.client = &http.Client{
+		Transport: rateLimiter,
+	}
+	githubConf.paginatedClient = &http.Client{
+		Transport: githubpagination.New(rateLimiter),
+	}
+
+	tokenSource := ""
+
+	envVars := []string{"GITHUB_TOKEN", "GH_TOKEN"}
+	if githubConf.host != "github.com" {
+		// same as gh does
+		envVars = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+	}
+
+	if conf.Token != "" {
+		githubConf.token, tokenSource = conf.Token, "--token"
+	} else {
+		for _, env := range envVars {
+			if os.Getenv(env) != "" {
+				githubConf.token, tokenSource = os.Getenv(env), "$"+env
+				break
+			}
+		}
+	}
+
+	if githubConf.token != "" {
+		githubConf.auth = githubAuthToken
+		logs.Debugf("github auth: using token from %s", tokenSource)
+		return
+	}
+
+	if _, err := exec.LookPath("gh"); err == nil {
+		githubConf.auth = githubAuthGh
+		logs.Debugf("github auth: using gh tool")
+		return
+	}
+
+	githubConf.auth = githubAuthNone
+	logs.Debugf("github auth: no token and no gh tool found," +
+		" using anonymous requests with low rate limits")
+}
+
 func githubPopulate(author defs.Author, conf defs.Config) (defs.Author, error) {
+	githubInit(conf)
+
 	project := conf.Project
 	if project == "" {
 		project = githubProject()
@@ -44,12 +198,16 @@ func githubPopulate(author defs.Author, conf defs.Config) (defs.Author, error) {
 		author.Email = gitEmail
 	}
 
+	if author.Login == "" {
+		author.Login = githubCommitLogin(project, author.Commit)
+	}
+
 	if author.Login == "" {
 		author.Login = githubLogin(project, gitName, gitEmail)
 	}
 
 	if author.Login != "" {
-		author.Profile = fmt.Sprintf("https://github.com/%s", author.Login)
+		author.Profile = fmt.Sprintf("%s/%s", githubConf.webURL, author.Login)
 	}
 
 	if author.Email == "" && author.Login != "" {
@@ -60,6 +218,17 @@ func githubPopulate(author defs.Author, conf defs.Config) (defs.Author, error) {
 		author.Name = githubName(project, author.Login, author.Name)
 	}
 
+	if author.Login != "" {
+		user := githubProfile(author.Login)
+
+		author.Avatar = githubAvatarURL(user.Avatar, conf.AvatarSize)
+		author.Company = user.Company
+		author.Website = user.Website
+		author.Location = user.Location
+		author.Twitter = user.Twitter
+		author.Bio = user.Bio
+	}
+
 	return author, nil
 }
 
@@ -69,18 +238,18 @@ func githubLogin(project, gitName, gitEmail string) (login string) {
 	}
 
 	defer func() {
-		cache.DiskStore([]string{"github", "n2l", gitName}, login)
-		cache.DiskStore([]string{"github", "e2l", gitEmail}, login)
+		cache.DiskStore([]string{githubConf.cacheNs, "n2l", gitName}, login)
+		cache.DiskStore([]string{githubConf.cacheNs, "e2l", gitEmail}, login)
 	}()
 
 	var found bool
 
-	login, found = cache.DiskLoad([]string{"github", "n2l", gitName})
+	login, found = cache.DiskLoad([]string{githubConf.cacheNs, "n2l", gitName})
 	if found {
 		return login
 	}
 
-	login, found = cache.DiskLoad([]string{"github", "e2l", gitEmail})
+	login, found = cache.DiskLoad([]string{githubConf.cacheNs, "e2l", gitEmail})
 	if found {
 		return login
 	}
@@ -185,12 +354,12 @@ func githubName(project, login, gitName string) (name string) {
 	}
 
 	defer func() {
-		cache.DiskStore([]string{"github", "l2n", login}, name)
+		cache.DiskStore([]string{githubConf.cacheNs, "l2n", login}, name)
 	}()
 
 	var found bool
 
-	name, found = cache.DiskLoad([]string{"github", "l2n", login})
+	name, found = cache.DiskLoad([]string{githubConf.cacheNs, "l2n", login})
 	if found {
 		return name
 	}
@@ -218,12 +387,7 @@ func githubName(project, login, gitName string) (name string) {
 		}
 	}
 
-	profileName := ""
-
-	profile := githubRequest("/users/"+login, false)
-	if profile != nil {
-		profileName, _ = profile.Path("name").Data().(string)
-	}
+	profileName := githubProfile(login).Name
 
 	switch {
 	case spaceRx.MatchString(commitName) ||
@@ -245,12 +409,12 @@ func githubEmail(project, login, nameHint string) (email string) {
 	}
 
 	defer func() {
-		cache.DiskStore([]string{"github", "l2e", login}, email)
+		cache.DiskStore([]string{githubConf.cacheNs, "l2e", login}, email)
 	}()
 
 	var found bool
 
-	email, found = cache.DiskLoad([]string{"github", "l2e", login})
+	email, found = cache.DiskLoad([]string{githubConf.cacheNs, "l2e", login})
 	if found {
 		return email
 	}
@@ -275,6 +439,79 @@ func githubEmail(project, login, nameHint string) (email string) {
 	return ""
 }
 
+// Subset of github user profile that we cache.
+type githubUser struct {
+	Name     string `json:"name,omitempty"`
+	Avatar   string `json:"avatar,omitempty"`
+	Company  string `json:"company,omitempty"`
+	Website  string `json:"website,omitempty"`
+	Location string `json:"location,omitempty"`
+	Twitter  string `json:"twitter,omitempty"`
+	Bio      string `json:"bio,omitempty"`
+}
+
+func githubProfile(login string) (user githubUser) {
+	if login == "" {
+		return
+	}
+
+	defer githubLock(githubConf.cacheNs, "l2p", login)()
+
+	defer func() {
+		cache.DiskStore([]string{githubConf.cacheNs, "l2p", login},
+			cache.Serialize(user))
+	}()
+
+	data, found := cache.DiskLoad([]string{githubConf.cacheNs, "l2p", login})
+	if found {
+		cache.Deserialize(data, &user)
+		return
+	}
+
+	profile := githubRequest("/users/"+login, false)
+	if profile == nil {
+		return
+	}
+
+	field := func(path string) string {
+		str, _ := profile.Path(path).Data().(string)
+		// bio and others may be multi-line
+		return strings.Join(strings.Fields(str), " ")
+	}
+
+	user.Name = field("name")
+	user.Avatar = field("avatar_url")
+	user.Company = field("company")
+	user.Website = field("blog")
+	user.Location = field("location")
+	user.Twitter = field("twitter_username")
+	user.Bio = field("bio")
+
+	if user.Website != "" && !strings.Contains(user.Website, "://") {
+		user.Website = "https://" + user.Website
+	}
+
+	return
+}
+
+// Add size parameter to avatar url.
+func githubAvatarURL(avatar string, size int) string {
+	if avatar == "" || size <= 0 {
+		return avatar
+	}
+
+	u, err := url.Parse(avatar)
+	if err != nil {
+		return avatar
+	}
+
+	query := u.Query()
+	query.Set("s", strconv.Itoa(size))
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
 type githubCommit struct {
 	Email string `json:"e"`
 	Name  string `json:"n"`
@@ -305,12 +542,14 @@ func githubPullreqCommits(project, login string) (commits []githubCommit) {
 		return nil
 	}
 
+	defer githubLock(githubConf.cacheNs, "pc", project, login)()
+
 	defer func() {
-		cache.DiskStore([]string{"github", "pc", project, login},
+		cache.DiskStore([]string{githubConf.cacheNs, "pc", project, login},
 			cache.Serialize(commits))
 	}()
 
-	data, found := cache.DiskLoad([]string{"github", "pc", project, login})
+	data, found := cache.DiskLoad([]string{githubConf.cacheNs, "pc", project, login})
 	if found {
 		cache.Deserialize(data, &commits)
 		return
@@ -365,12 +604,14 @@ func githubContribCommits(project, login string) (commits []githubCommit) {
 		return nil
 	}
 
+	defer githubLock(githubConf.cacheNs, "cc", project, login)()
+
 	defer func() {
-		cache.DiskStore([]string{"github", "cc", project, login},
+		cache.DiskStore([]string{githubConf.cacheNs, "cc", project, login},
 			cache.Serialize(commits))
 	}()
 
-	data, found := cache.DiskLoad([]string{"github", "cc", project, login})
+	data, found := cache.DiskLoad([]string{githubConf.cacheNs, "cc", project, login})
 	if found {
 		cache.Deserialize(data, &commits)
 		return
@@ -404,12 +645,14 @@ func githubEventCommits(login string) (commits []githubCommit) {
 		return nil
 	}
 
+	defer githubLock(githubConf.cacheNs, "ec", login)()
+
 	defer func() {
-		cache.DiskStore([]string{"github", "ec", login},
+		cache.DiskStore([]string{githubConf.cacheNs, "ec", login},
 			cache.Serialize(commits))
 	}()
 
-	data, found := cache.DiskLoad([]string{"github", "ec", login})
+	data, found := cache.DiskLoad([]string{githubConf.cacheNs, "ec", login})
 	if found {
 		cache.Deserialize(data, &commits)
 		return
@@ -443,12 +686,14 @@ func githubContributors(project string) (contributors []string) {
 		return nil
 	}
 
+	defer githubLock(githubConf.cacheNs, "contrib", project)()
+
 	defer func() {
-		cache.MemStore([]string{"github", "contrib", project},
+		cache.MemStore([]string{githubConf.cacheNs, "contrib", project},
 			cache.Serialize(contributors))
 	}()
 
-	data, found := cache.MemLoad([]string{"github", "contrib", project})
+	data, found := cache.MemLoad([]string{githubConf.cacheNs, "contrib", project})
 	if found {
 		cache.Deserialize(data, &contributors)
 		return
@@ -467,22 +712,18 @@ func githubContributors(project string) (contributors []string) {
 	return contributors
 }
 
-var githubClient = &http.Client{
-	Transport: func() http.RoundTripper {
-		rateLimiter, _ := github_ratelimit.NewRateLimitWaiter(nil)
-		return rateLimiter
-	}(),
-}
+var githubLocks sync.Map
 
-var githubPaginatingClient = &http.Client{
-	Transport: func() http.RoundTripper {
-		rateLimiter, _ := github_ratelimit.NewRateLimitWaiter(nil)
-		return githubpagination.New(rateLimiter)
-	}(),
+// Acquire lock for given cache key and return unlock function.
+// Used to prevent concurrent workers from fetching same data twice.
+func githubLock(keys ...string) func() {
+	mu, _ := githubLocks.LoadOrStore(strings.Join(keys, ":"), &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
 }
 
 func githubRequest(endpoint string, paginate bool, queryArgs ...string) *gabs.Container {
-	req, _ := http.NewRequest("GET", "https://api.github.com"+endpoint, nil)
+	req, _ := http.NewRequest("GET", githubConf.apiURL+endpoint, nil)
 
 	req.Header.Add("accept", "application/vnd.github.v3+json")
 
@@ -492,11 +733,15 @@ func githubRequest(endpoint string, paginate bool, queryArgs ...string) *gabs.Co
 	}
 	req.URL.RawQuery = q.Encode()
 
-	// If 'gh' is eavailable, use it instead of direct HTTP request.
-	// While direct request will also work as we only access publically available
-	// data, if 'gh' tool is authenticated, it has higher rate limits.
-	if _, err := exec.LookPath("gh"); err == nil {
+	// If token is not provided, but 'gh' is available, use it instead of direct
+	// HTTP request. While direct request will also work as we only access
+	// publically available data, if 'gh' tool is authenticated, it has higher
+	// rate limits.
+	if githubConf.auth == githubAuthGh {
 		cmdArgs := []string{"gh", "api"}
+		if githubConf.host != "github.com" {
+			cmdArgs = append(cmdArgs, "--hostname", githubConf.host)
+		}
 		if paginate {
 			cmdArgs = append(cmdArgs, "--paginate")
 		}
@@ -518,11 +763,15 @@ func githubRequest(endpoint string, paginate bool, queryArgs ...string) *gabs.Co
 		}
 	}
 
+	if githubConf.auth == githubAuthToken {
+		req.Header.Add("authorization", "Bearer "+githubConf.token)
+	}
+
 	logs.Debugf("sending: %s %s", req.Method, req.URL.String())
 
-	client := githubClient
+	client := githubConf.client
 	if paginate {
-		client = githubPaginatingClient
+		client = githubConf.paginatedClient
 	}
 
 	resp, err := client.Do(req)
@@ -569,16 +818,8 @@ func githubProject() string {
 			remote := fields[0]
 			uri := fields[1]
 
-			switch {
-			case strings.HasPrefix(uri, "git@github.com:"):
-				uri = strings.TrimPrefix(uri, "git@github.com:")
-				uri = strings.TrimSuffix(uri, ".git")
-
-			case strings.HasPrefix(uri, "https://github.com/"):
-				uri = strings.TrimPrefix(uri, "https://github.com/")
-				uri = strings.TrimSuffix(uri, ".git")
-
-			default:
+			uri, ok := githubRemotePath(uri)
+			if !ok {
 				continue
 			}
 
@@ -602,3 +843,22 @@ func githubProject() string {
 
 	return project
 }
+
+// Strip recognized host from remote uri and return project path.
+func githubRemotePath(uri string) (string, bool) {
+	for _, host := range githubConf.remoteHosts {
+		for _, prefix := range []string{
+			"git@" + host + ":",
+			"ssh://git@" + host + "/",
+			"https://" + host + "/",
+		} {
+			if strings.HasPrefix(uri, prefix) {
+				uri = strings.TrimPrefix(uri, prefix)
+				uri = strings.TrimSuffix(uri, ".git")
+				return uri, true
+			}
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,185 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+
+	"github.com/Jeffail/gabs/v2"
+
+	"github.com/gavv/md-authors/src/cache"
+	"github.com/gavv/md-authors/src/defs"
+	"github.com/gavv/md-authors/src/logs"
+)
+
+// How many commits to resolve in one GraphQL query.
+const githubBatchSize = 50
+
+// Resolve logins of authors of given commits using GraphQL batch queries.
+// For every commit, GitHub already knows which user is linked to commit
+// author email, so this is much cheaper than searching users one by one.
+// Results are stored in cache and later picked by githubCommitLogin().
+func githubResolveCommits(authors []defs.Author, conf defs.Config) {
+	project := conf.Project
+	if project == "" {
+		project = githubProject()
+	}
+
+	owner, repo, ok := strings.Cut(project, "/")
+	if !ok {
+		return
+	}
+
+	var commits []string
+
+	for _, author := range authors {
+		if author.Commit == "" || noreplyRx.MatchString(author.Email) {
+			continue
+		}
+		if _, found := cache.DiskLoad([]string{githubConf.cacheNs, "c2l", project, author.Commit}); found {
+			continue
+		}
+		commits = append(commits, author.Commit)
+	}
+
+	if len(commits) == 0 {
+		return
+	}
+
+	logs.Debugf("resolving %d commits via graphql", len(commits))
+
+	for len(commits) > 0 {
+		batch := commits[:min(githubBatchSize, len(commits))]
+		commits = commits[len(batch):]
+
+		var query strings.Builder
+
+		query.WriteString("query($owner: String!, $repo: String!) {\n")
+		query.WriteString(" repository(owner: $owner, name: $repo) {\n")
+		for n, commit := range batch {
+			fmt.Fprintf(&query,
+				"  c%d: object(oid: %q) { ... on Commit { author { user { login } } } }\n",
+				n, commit)
+		}
+		query.WriteString(" }\n")
+		query.WriteString("}\n")
+
+		result := githubGraphQL(query.String(), "owner", owner, "repo", repo)
+		if result == nil {
+			// GraphQL is not available, fallback to heuristics
+			return
+		}
+
+		for n, commit := range batch {
+			object := result.Path(fmt.Sprintf("data.repository.c%d", n))
+			if object.Data() == nil {
+				// commit is unknown to github, e.g. not pushed yet, or
+				// force-pushed away; don't cache, so that it's retried
+				logs.Debugf("commit %s not found on github", commit)
+				continue
+			}
+
+			// empty login means that commit has no linked user
+			login, _ := object.Path("author.user.login").Data().(string)
+
+			cache.DiskStore([]string{githubConf.cacheNs, "c2l", project, commit}, login)
+		}
+	}
+}
+
+// Get login of commit author resolved by githubResolveCommits().
+func githubCommitLogin(project, commit string) string {
+	if project == "" || commit == "" {
+		return ""
+	}
+
+	login, _ := cache.DiskLoad([]string{githubConf.cacheNs, "c2l", project, commit})
+
+	return login
+}
+
+func githubGraphQL(query string, queryVars ...string) *gabs.Container {
+	switch githubConf.auth {
+	case githubAuthToken:
+		return githubGraphQLHTTP(query, queryVars...)
+	case githubAuthGh:
+		return githubGraphQLGh(query, queryVars...)
+	default:
+		// GraphQL API requires authentication
+		logs.Debugf("no github auth, skipping graphql query")
+		return nil
+	}
+}
+
+func githubGraphQLHTTP(query string, queryVars ...string) *gabs.Container {
+	vars := make(map[string]string)
+	for i := 0; i < len(queryVars); i += 2 {
+		vars[queryVars[i]] = queryVars[i+1]
+	}
+
+	reqBody, _ := json.Marshal(map[string]any{
+		"query":     query,
+		"variables": vars,
+	})
+
+	req, _ := http.NewRequest("POST", githubConf.graphqlURL,
+		bytes.NewReader(reqBody))
+
+	req.Header.Add("content-type", "application/json")
+	req.Header.Add("authorization", "Bearer "+githubConf.token)
+
+	logs.Debugf("sending: %s %s", req.Method, req.URL.String())
+
+	resp, err := githubConf.client.Do(req)
+	if err != nil {
+		logs.Debugf("graphql query failed: %s", err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil
+	}
+
+	js, err := gabs.ParseJSON(body)
+	if err != nil || !js.Exists("data") {
+		logs.Debugf("graphql query failed: %s", resp.Status)
+		return nil
+	}
+
+	return js
+}
+
+func githubGraphQLGh(query string, queryVars ...string) *gabs.Container {
+	cmdArgs := []string{"gh", "api", "graphql"}
+	if githubConf.host != "github.com" {
+		cmdArgs = append(cmdArgs, "--hostname", githubConf.host)
+	}
+	for i := 0; i < len(queryVars); i += 2 {
+		cmdArgs = append(cmdArgs, "-f", queryVars[i]+"="+queryVars[i+1])
+	}
+
+	logs.Debugf("running: %s", strings.Join(cmdArgs, " "))
+
+	cmdArgs = append(cmdArgs, "-f", "query="+query)
+
+	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err := cmd.Run()
+
+	// on partial failure, gh exits with error, but still prints data
+	js, jsErr := gabs.ParseJSON(out.Bytes())
+	if jsErr != nil || !js.Exists("data") {
+		logs.Debugf("graphql query failed: %v", err)
+		return nil
+	}
+
+	return js
+}
@@ -0,0 +1,510 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gavv/md-authors/src/cache"
+	"github.com/gavv/md-authors/src/defs"
+)
+
+// When set, requests are forwarded to real GitHub API (authenticated
+// with $GITHUB_TOKEN) and responses are written to fixture files.
+var recordFlag = flag.Bool("record", false, "record github fixtures")
+
+// Fixture maps request "METHOD /path?query" to response body.
+// Query is normalized, so fixture keys may be written unescaped.
+type githubFixture map[string]json.RawMessage
+
+func fixtureKey(method, uri string) string {
+	path, query, _ := strings.Cut(uri, "?")
+	if query == "" {
+		return method + " " + path
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return method + " " + uri
+	}
+	return method + " " + path + "?" + values.Encode()
+}
+
+// Start replay server serving responses from testdata/github/<name>.json
+// and configure github backend to use it.
+// Returned function reports how many times each request was made.
+func setupGithub(t *testing.T, name string) (requests func() map[string]int) {
+	t.Helper()
+
+	path := filepath.Join("testdata", "github", name+".json")
+
+	fixture := make(githubFixture)
+	if !*recordFlag {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("can't read fixture: %s", err)
+		}
+		if err := json.Unmarshal(b, &fixture); err != nil {
+			t.Fatalf("can't parse fixture %q: %s", path, err)
+		}
+	}
+
+	responses := make(map[string]json.RawMessage)
+	for key, body := range fixture {
+		method, uri, _ := strings.Cut(key, " ")
+		responses[fixtureKey(method, uri)] = body
+	}
+
+	var mu sync.Mutex
+	counts := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			key := fixtureKey(r.Method, r.URL.RequestURI())
+			counts[key]++
+
+			if *recordFlag {
+				body := recordRequest(t, r)
+				fixture[key] = body
+				responses[key] = body
+			}
+
+			body, ok := responses[key]
+			if !ok {
+				t.Logf("no fixture for %q", key)
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"message":"Not Found"}`))
+				return
+			}
+
+			w.Header().Set("content-type", "application/json")
+			w.Write(body)
+		}))
+
+	t.Cleanup(func() {
+		server.Close()
+
+		if *recordFlag {
+			b, _ := json.MarshalIndent(fixture, "", "  ")
+			if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+				t.Errorf("can't write fixture: %s", err)
+			}
+		}
+	})
+
+	// restore globals, so that state doesn't leak into other tests
+	restoreGithubConf(t)
+	oldTransport := githubTransport
+	t.Cleanup(func() {
+		githubTransport = oldTransport
+		cache.SetStore(cache.NewMemoryStore())
+	})
+
+	cache.SetStore(cache.NewMemoryStore())
+
+	githubTransport = server.Client().Transport
+	githubOnce.Do(func() {})
+	githubConfigure(defs.Config{
+		GithubAPI: server.URL,
+		Token:     "test",
+	})
+
+	return func() map[string]int {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return maps.Clone(counts)
+	}
+}
+
+// Restore github globals modified by githubConfigure.
+func restoreGithubConf(t *testing.T) {
+	oldConf, oldNoreplyRx := githubConf, noreplyRx
+	t.Cleanup(func() {
+		githubConf, noreplyRx = oldConf, oldNoreplyRx
+	})
+}
+
+func recordRequest(t *testing.T, r *http.Request) json.RawMessage {
+	reqBody, _ := io.ReadAll(r.Body)
+
+	req, _ := http.NewRequest(r.Method,
+		"https://api.github.com"+r.URL.RequestURI(), bytes.NewReader(reqBody))
+	req.Header.Set("accept", r.Header.Get("accept"))
+	req.Header.Set("content-type", r.Header.Get("content-type"))
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("can't record %s %s: %s", r.Method, r.URL, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if !json.Valid(body) {
+		t.Fatalf("can't record %s %s: invalid json", r.Method, r.URL)
+	}
+
+	return body
+}
+
+func TestGithubPopulate(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		author  defs.Author
+		want    defs.Author
+	}{
+		{
+			name:    "noreply email",
+			fixture: "noreply",
+			author: defs.Author{
+				Name:  "Ix",
+				Email: "1234+Ix@users.noreply.github.com",
+			},
+			want: defs.Author{
+				Name:     "Ford Prefect",
+				Email:    "ford@betelgeuse7.sid",
+				Login:    "Ix",
+				Profile:  "https://github.com/Ix",
+				Avatar:   "https://avatars.githubusercontent.com/u/1234?s=32&v=4",
+				Company:  "@megadodo",
+				Website:  "https://guide.megadodo.com",
+				Location: "Betelgeuse",
+				Twitter:  "ix",
+				Bio:      "Field researcher. Mostly harmless.",
+			},
+		},
+		{
+			name:    "search by name",
+			fixture: "name_search",
+			author: defs.Author{
+				Name:  "Arthur Dent",
+				Email: "dent@yahoo.com",
+			},
+			want: defs.Author{
+				Name:    "Arthur Dent",
+				Email:   "dent@yahoo.com",
+				Login:   "sandwich-maker",
+				Profile: "https://github.com/sandwich-maker",
+				Avatar:  "https://avatars.githubusercontent.com/u/42?s=32&v=4",
+			},
+		},
+		{
+			name:    "contributors fallback",
+			fixture: "contributors",
+			author: defs.Author{
+				Name:  "Tricia McMillan",
+				Email: "trillian@heartofgold.sid",
+			},
+			want: defs.Author{
+				Name:    "Tricia McMillan",
+				Email:   "trillian@heartofgold.sid",
+				Login:   "trillian",
+				Profile: "https://github.com/trillian",
+				Avatar:  "https://avatars.githubusercontent.com/u/7?s=32&v=4",
+			},
+		},
+		{
+			name:    "pull request commits",
+			fixture: "pullreq",
+			author: defs.Author{
+				Name:  "Zaphod Beeblebrox",
+				Email: "zaphod@heartofgold.sid",
+			},
+			want: defs.Author{
+				Name:    "Zaphod Beeblebrox",
+				Email:   "zaphod@heartofgold.sid",
+				Login:   "zbeeblebrox",
+				Profile: "https://github.com/zbeeblebrox",
+				Avatar:  "https://avatars.githubusercontent.com/u/2?s=32&v=4",
+			},
+		},
+		{
+			name:    "unknown author",
+			fixture: "unknown",
+			author: defs.Author{
+				Name:  "Marvin",
+				Email: "marvin@sirius.cyb",
+			},
+			want: defs.Author{
+				Email: "marvin@sirius.cyb",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupGithub(t, tt.fixture)
+
+			got, err := githubPopulate(tt.author, defs.Config{
+				Project:    "magrathea/earth",
+				AvatarSize: 32,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected author:\n got: %+v\nwant: %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGithubResolveCommits(t *testing.T) {
+	setupGithub(t, "graphql")
+
+	conf := defs.Config{
+		Project: "magrathea/earth",
+	}
+
+	authors := []defs.Author{
+		{
+			Name:   "Ford Prefect",
+			Email:  "ford@betelgeuse7.sid",
+			Commit: "1111111111111111111111111111111111111111",
+		},
+		{
+			Name:   "Marvin",
+			Email:  "marvin@sirius.cyb",
+			Commit: "2222222222222222222222222222222222222222",
+		},
+		{
+			Name:   "Zaphod Beeblebrox",
+			Email:  "zaphod@heartofgold.sid",
+			Commit: "3333333333333333333333333333333333333333",
+		},
+	}
+
+	githubResolveCommits(authors, conf)
+
+	var (
+		logins []string
+		cached []bool
+	)
+	for _, author := range authors {
+		logins = append(logins, githubCommitLogin(conf.Project, author.Commit))
+
+		_, found := cache.DiskLoad([]string{githubConf.cacheNs, "c2l", conf.Project, author.Commit})
+		cached = append(cached, found)
+	}
+
+	want := []string{"Ix", "", ""}
+	if !slices.Equal(logins, want) {
+		t.Errorf("unexpected logins: got %q, want %q", logins, want)
+	}
+
+	// commit without linked user is cached, unknown commit is not
+	wantCached := []bool{true, true, false}
+	if !slices.Equal(cached, wantCached) {
+		t.Errorf("unexpected cache state: got %v, want %v", cached, wantCached)
+	}
+}
+
+func TestPopulateAuthors(t *testing.T) {
+	requests := setupGithub(t, "populate")
+
+	ford := defs.Author{
+		Name:  "Ix",
+		Email: "1234+Ix@users.noreply.github.com",
+	}
+	arthur := defs.Author{
+		Name:  "Arthur Dent",
+		Email: "dent@yahoo.com",
+	}
+	zaphod := defs.Author{
+		Name:  "Zaphod Beeblebrox",
+		Email: "zaphod@heartofgold.sid",
+	}
+
+	// same author is repeated, so that workers look it up concurrently
+	authors := []defs.Author{ford, arthur, ford, zaphod, ford, ford}
+
+	got, err := PopulateAuthors(authors, defs.Config{
+		Project:    "magrathea/earth",
+		AvatarSize: 32,
+		Jobs:       4,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var names []string
+	for _, author := range got {
+		names = append(names, author.Name+" <"+author.Email+">")
+	}
+
+	// same order as input
+	want := []string{
+		"Ford Prefect <ford@betelgeuse7.sid>",
+		"Arthur Dent <dent@yahoo.com>",
+		"Ford Prefect <ford@betelgeuse7.sid>",
+		"Zaphod Beeblebrox <zaphod@heartofgold.sid>",
+		"Ford Prefect <ford@betelgeuse7.sid>",
+		"Ford Prefect <ford@betelgeuse7.sid>",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("unexpected authors:\n got: %q\nwant: %q", names, want)
+	}
+
+	// locked lookups are made once, even if requested by several workers
+	for key, count := range requests() {
+		if count != 1 {
+			t.Errorf("%q requested %d times", key, count)
+		}
+	}
+}
+
+func TestGithubConfigure(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    defs.Config
+		host    string
+		cacheNs string
+		api     string
+		graphql string
+		web     string
+		noreply string
+	}{
+		{
+			name:    "github.com",
+			conf:    defs.Config{GithubHost: "github.com"},
+			host:    "github.com",
+			cacheNs: "github",
+			api:     "https://api.github.com",
+			graphql: "https://api.github.com/graphql",
+			web:     "https://github.com",
+			noreply: "1234+Ix@users.noreply.github.com",
+		},
+		{
+			name:    "enterprise",
+			conf:    defs.Config{GithubHost: "ghe.example.com"},
+			host:    "ghe.example.com",
+			cacheNs: "github/ghe.example.com",
+			api:     "https://ghe.example.com/api/v3",
+			graphql: "https://ghe.example.com/api/graphql",
+			web:     "https://ghe.example.com",
+			noreply: "1234+Ix@users.noreply.ghe.example.com",
+		},
+		{
+			name:    "enterprise with port",
+			conf:    defs.Config{GithubHost: "ghe.example.com:8443"},
+			host:    "ghe.example.com:8443",
+			cacheNs: "github/ghe.example.com:8443",
+			api:     "https://ghe.example.com:8443/api/v3",
+			graphql: "https://ghe.example.com:8443/api/graphql",
+			web:     "https://ghe.example.com:8443",
+			noreply: "1234+Ix@users.noreply.ghe.example.com",
+		},
+		{
+			name: "enterprise with custom urls",
+			conf: defs.Config{
+				GithubHost: "ghe.example.com",
+				GithubAPI:  "https://api.ghe.example.com/v3/",
+				GithubWeb:  "https://web.ghe.example.com/",
+			},
+			host:    "ghe.example.com",
+			cacheNs: "github/ghe.example.com",
+			api:     "https://api.ghe.example.com/v3",
+			graphql: "https://api.ghe.example.com/graphql",
+			web:     "https://web.ghe.example.com",
+			noreply: "1234+Ix@users.noreply.ghe.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restoreGithubConf(t)
+
+			// explicit token, so that gh tool isn't looked up
+			tt.conf.Token = "test"
+			githubConfigure(tt.conf)
+
+			got := []string{githubConf.host, githubConf.cacheNs,
+				githubConf.apiURL, githubConf.graphqlURL, githubConf.webURL}
+			want := []string{tt.host, tt.cacheNs, tt.api, tt.graphql, tt.web}
+			if !slices.Equal(got, want) {
+				t.Errorf("unexpected config:\n got: %q\nwant: %q", got, want)
+			}
+
+			if m := noreplyRx.FindStringSubmatch(tt.noreply); m == nil || m[2] != "Ix" {
+				t.Errorf("noreply email %q not recognized", tt.noreply)
+			}
+			if tt.host != "github.com" && noreplyRx.MatchString("1234+Ix@users.noreply.github.com") {
+				t.Errorf("github.com noreply email recognized for %s", tt.host)
+			}
+		})
+	}
+}
+
+func TestGithubRemotePath(t *testing.T) {
+	tests := []struct {
+		conf defs.Config
+		uri  string
+		want string
+	}{
+		{defs.Config{}, "git@github.com:magrathea/earth.git", "magrathea/earth"},
+		{defs.Config{}, "ssh://git@github.com/magrathea/earth.git", "magrathea/earth"},
+		{defs.Config{}, "https://github.com/magrathea/earth", "magrathea/earth"},
+		{defs.Config{}, "https://gitlab.com/magrathea/earth.git", ""},
+		{defs.Config{}, "git@ghe.example.com:magrathea/earth.git", ""},
+		{
+			defs.Config{GithubHost: "ghe.example.com"},
+			"git@ghe.example.com:magrathea/earth.git",
+			"magrathea/earth",
+		},
+		{
+			defs.Config{GithubHost: "ghe.example.com"},
+			"https://ghe.example.com/magrathea/earth.git",
+			"magrathea/earth",
+		},
+		{
+			defs.Config{GithubHost: "ghe.example.com"},
+			"git@github.com:magrathea/earth.git",
+			"",
+		},
+		{
+			defs.Config{GithubHost: "ghe.example.com:8443"},
+			"https://ghe.example.com:8443/magrathea/earth.git",
+			"magrathea/earth",
+		},
+		{
+			defs.Config{
+				GithubHost:    "ghe.example.com",
+				GithubRemotes: []string{"ghe.example.com", "git.example.com"},
+			},
+			"git@git.example.com:magrathea/earth.git",
+			"magrathea/earth",
+		},
+	}
+
+	restoreGithubConf(t)
+
+	for _, tt := range tests {
+		tt.conf.Token = "test"
+		githubConfigure(tt.conf)
+
+		got, ok := githubRemotePath(tt.uri)
+		if ok != (tt.want != "") || got != tt.want {
+			t.Errorf("githubRemotePath(%q) with host %q: got (%q, %v), want %q",
+				tt.uri, tt.conf.GithubHost, got, ok, tt.want)
+		}
+	}
+}
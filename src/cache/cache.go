@@ -1,83 +1,145 @@
 package cache
 
 import (
-	"encoding/json"
-	"os"
+	"sort"
 	"strings"
 	"sync"
-
-	"github.com/gofrs/flock"
+	"time"
 
 	"github.com/gavv/md-authors/src/logs"
 )
 
 var Refresh = false
 
+// Entry is a single record of disk cache.
+type Entry struct {
+	Key   string
+	Value string
+	Time  time.Time
+}
+
+// Pending disk writes are batched and flushed when there are this many
+// of them, or when this much time passed since last flush, or on Flush().
+const (
+	diskBatchSize     = 32
+	diskBatchInterval = 5 * time.Second
+)
+
 var (
 	memCache  map[string]string   = make(map[string]string)
-	diskCache map[string]string   = make(map[string]string)
+	diskCache map[string]Entry    = make(map[string]Entry)
+	diskDirty map[string]*Entry   = make(map[string]*Entry)
 	reCache   map[string]struct{} = make(map[string]struct{})
-	diskFile  string
+	diskStore Store
+	diskFlush time.Time
 	diskOnce  sync.Once
+	mutex     sync.Mutex
 )
 
+// SetStore sets backing storage of disk cache and drops all cached data.
+// Pending changes are not flushed to the old store.
+// If not called, file store with DefaultPath() is used.
+func SetStore(store Store) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	memCache = make(map[string]string)
+	diskCache = make(map[string]Entry)
+	diskDirty = make(map[string]*Entry)
+	reCache = make(map[string]struct{})
+	diskStore = store
+	diskOnce = sync.Once{}
+}
+
 func diskInit() {
 	diskOnce.Do(func() {
-		diskDir, _ := os.UserCacheDir()
-		diskFile = diskDir + "/mdauthors.json"
-
-		// ensure file exists
-		file, err := os.OpenFile(diskFile, os.O_RDONLY|os.O_CREATE, 0666)
-		if err != nil {
-			logs.Fatalf("failed to open or create %q", diskFile)
+		if diskStore == nil {
+			diskStore = NewFileStore(DefaultPath())
 		}
-		file.Close()
 
-		// acquire shared lock
-		lock := flock.New(diskFile)
-		if err := lock.RLock(); err != nil {
-			logs.Fatalf("failed to acquire shared lock on %q", diskFile)
+		entries, err := diskStore.Load()
+		if err != nil {
+			logs.Fatalf("%s", err)
 		}
-		defer lock.Unlock()
 
-		b, _ := os.ReadFile(diskFile)
-		if err := json.Unmarshal(b, &diskCache); err != nil {
-			diskCache = make(map[string]string)
-		}
+		diskCache = entries
+		diskFlush = time.Now()
 
-		logs.Debugf("loaded %d entries from %q", len(diskCache), diskFile)
+		logs.Debugf("loaded %d entries from %q", len(diskCache), diskStore.Name())
 	})
 }
 
-func DiskStore(keys []string, value string) {
-	diskInit()
+// Flush writes pending changes to store.
+// Changes made concurrently by other processes are merged with ours.
+func Flush() {
+	mutex.Lock()
+	defer mutex.Unlock()
 
-	key := strings.Join(keys, ":")
+	diskFlushLocked()
+}
 
-	if val, ok := diskCache[key]; ok && val == value {
+func diskFlushLocked() {
+	if len(diskDirty) == 0 {
 		return
 	}
 
-	logs.Debugf("cache store: %q %q", key, value)
-	diskCache[key] = value
+	entries, err := diskStore.Merge(diskDirty)
+	if err != nil {
+		logs.Fatalf("%s", err)
+	}
+
+	logs.Debugf("flushed %d changes to %q", len(diskDirty), diskStore.Name())
 
-	// acquire exclusive lock
-	lock := flock.New(diskFile)
-	if err := lock.Lock(); err != nil {
-		logs.Fatalf("failed to acquire exclusive lock on %q", diskFile)
+	diskCache = entries
+	diskDirty = make(map[string]*Entry)
+	diskFlush = time.Now()
+}
+
+func diskUpdate(key string, e *Entry) {
+	if e != nil {
+		diskCache[key] = *e
+	} else {
+		delete(diskCache, key)
+	}
+	diskDirty[key] = e
+
+	if len(diskDirty) >= diskBatchSize || time.Since(diskFlush) >= diskBatchInterval {
+		diskFlushLocked()
+	}
+}
+
+func DiskStore(keys []string, value string) {
+	diskInit()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	key := strings.Join(keys, ":")
+
+	if e, ok := diskCache[key]; ok && e.Value == value {
+		// if entry was reset by --refresh, bump its timestamp
+		if _, ok := reCache[key]; !ok {
+			return
+		}
 	}
-	defer lock.Unlock()
 
-	b, _ := json.MarshalIndent(diskCache, "", " ")
-	os.WriteFile(diskFile, b, 0644)
+	logs.Debugf("cache store: %q %q", key, value)
+	diskUpdate(key, &Entry{
+		Key:   key,
+		Value: value,
+		Time:  time.Unix(time.Now().Unix(), 0),
+	})
 }
 
 func DiskLoad(keys []string) (string, bool) {
 	diskInit()
 
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	key := strings.Join(keys, ":")
 
-	if value, ok := diskCache[key]; ok {
+	if e, ok := diskCache[key]; ok {
 		if Refresh {
 			if _, ok := reCache[key]; !ok {
 				logs.Debugf("cache reset: %q", key)
@@ -86,23 +148,105 @@ func DiskLoad(keys []string) (string, bool) {
 			}
 		}
 
-		logs.Debugf("cache hit: %q %q", key, value)
-		return value, true
+		logs.Debugf("cache hit: %q %q", key, e.Value)
+		return e.Value, true
 	}
 
 	logs.Debugf("cache miss: %q", key)
 	return "", false
 }
 
+// DiskEntries returns all disk cache entries, sorted by key.
+func DiskEntries() []Entry {
+	diskInit()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	entries := make([]Entry, 0, len(diskCache))
+	for _, e := range diskCache {
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	return entries
+}
+
+// DiskDelete removes entries with given keys from disk cache.
+func DiskDelete(keys []string) {
+	diskInit()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, key := range keys {
+		if _, ok := diskCache[key]; ok {
+			logs.Debugf("cache delete: %q", key)
+			diskUpdate(key, nil)
+		}
+	}
+
+	diskFlushLocked()
+}
+
+// DiskImport merges entries into disk cache.
+// If entry already exists, the most recent one wins.
+func DiskImport(entries []Entry) {
+	diskInit()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, e := range entries {
+		if old, ok := diskCache[e.Key]; ok && !old.Time.Before(e.Time) {
+			continue
+		}
+		logs.Debugf("cache import: %q %q", e.Key, e.Value)
+		diskUpdate(e.Key, &e)
+	}
+
+	diskFlushLocked()
+}
+
+func makeEntry(key string, ent diskEntry) Entry {
+	e := Entry{
+		Key:   key,
+		Value: ent.Value,
+	}
+	if ent.Time != 0 {
+		e.Time = time.Unix(ent.Time, 0)
+	}
+	return e
+}
+
+func makeDiskEntry(e Entry) diskEntry {
+	ent := diskEntry{
+		Value: e.Value,
+	}
+	if !e.Time.IsZero() {
+		ent.Time = e.Time.Unix()
+	}
+	return ent
+}
+
 func MemStore(keys []string, value string) {
 	key := strings.Join(keys, ":")
 
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	memCache[key] = value
 }
 
 func MemLoad(keys []string) (string, bool) {
 	key := strings.Join(keys, ":")
 
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	if value, ok := memCache[key]; ok {
 		return value, true
 	}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// Store that counts Merge() calls.
+type countingStore struct {
+	Store
+	merges int
+}
+
+func (s *countingStore) Merge(changes map[string]*Entry) (map[string]Entry, error) {
+	s.merges++
+	return s.Store.Merge(changes)
+}
+
+// Replace cache store until the end of the test.
+func setupStore(t *testing.T, store Store) {
+	t.Helper()
+
+	SetStore(store)
+	t.Cleanup(func() {
+		SetStore(NewMemoryStore())
+	})
+}
+
+func TestDiskFlush(t *testing.T) {
+	store := &countingStore{Store: NewMemoryStore()}
+	setupStore(t, store)
+
+	check := func(merges, entries int) {
+		t.Helper()
+
+		if store.merges != merges {
+			t.Errorf("unexpected merge count: got %d, want %d", store.merges, merges)
+		}
+		stored, _ := store.Load()
+		if len(stored) != entries {
+			t.Errorf("unexpected stored entries: got %d, want %d", len(stored), entries)
+		}
+	}
+
+	for n := 0; n < diskBatchSize-1; n++ {
+		DiskStore([]string{"github", "l2n", "login" + strconv.Itoa(n)}, "name")
+	}
+	check(0, 0)
+
+	// pending entries are visible before flush
+	if value, ok := DiskLoad([]string{"github", "l2n", "login0"}); !ok || value != "name" {
+		t.Errorf("unexpected load result: got (%q, %v)", value, ok)
+	}
+
+	// batch is full
+	DiskStore([]string{"github", "l2n", "login" + strconv.Itoa(diskBatchSize-1)}, "name")
+	check(1, diskBatchSize)
+
+	// storing same value is not a change
+	DiskStore([]string{"github", "l2n", "login0"}, "name")
+	Flush()
+	check(1, diskBatchSize)
+
+	DiskStore([]string{"github", "l2n", "marvin"}, "Marvin")
+	check(1, diskBatchSize)
+
+	Flush()
+	check(2, diskBatchSize+1)
+
+	// nothing to flush
+	Flush()
+	check(2, diskBatchSize+1)
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	setupStore(t, NewMemoryStore())
+
+	const (
+		numWorkers = 8
+		numKeys    = 100
+	)
+
+	var wg sync.WaitGroup
+
+	for w := range numWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range numKeys {
+				key := []string{"github", "l2n", "login" + strconv.Itoa(n)}
+				value := "name" + strconv.Itoa(w)
+
+				DiskStore(key, value)
+				DiskLoad(key)
+				MemStore(key, value)
+				MemLoad(key)
+			}
+		}()
+	}
+
+	wg.Wait()
+	Flush()
+
+	if entries := DiskEntries(); len(entries) != numKeys {
+		t.Errorf("unexpected entry count: got %d, want %d", len(entries), numKeys)
+	}
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"encoding/base64"
 	"encoding/json"
+	"sort"
 )
 
 func Serialize(data any) string {
@@ -26,3 +27,47 @@ func Deserialize(str string, data any) {
 	}
 	json.Unmarshal(b, data)
 }
+
+// Decode returns JSON text of a value produced by Serialize().
+// If value doesn't look like serialized data, returns false.
+func Decode(str string) (string, bool) {
+	if str == "" {
+		return "", false
+	}
+	b, err := base64.StdEncoding.DecodeString(str)
+	if err != nil || !json.Valid(b) {
+		return "", false
+	}
+	switch b[0] {
+	case '[', '{', '"':
+		return string(b), true
+	}
+	return "", false
+}
+
+// MarshalEntries encodes entries in the same format as used by cache file.
+func MarshalEntries(entries []Entry) []byte {
+	m := make(map[string]diskEntry, len(entries))
+	for _, e := range entries {
+		m[e.Key] = makeDiskEntry(e)
+	}
+	b, _ := json.MarshalIndent(m, "", " ")
+	return b
+}
+
+// UnmarshalEntries decodes entries produced by MarshalEntries() or read
+// from cache file.
+func UnmarshalEntries(b []byte) ([]Entry, error) {
+	var m map[string]diskEntry
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(m))
+	for key, ent := range m {
+		entries = append(entries, makeEntry(key, ent))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries, nil
+}
@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gofrs/flock"
+)
+
+// Store is a backing storage of disk cache.
+type Store interface {
+	// Human-readable store name, used in logs.
+	Name() string
+
+	// Load all entries from storage.
+	Load() (map[string]Entry, error)
+
+	// Atomically apply changes to storage and return its new contents,
+	// which may also include changes made concurrently by others.
+	// Nil entry means that the key should be removed.
+	Merge(changes map[string]*Entry) (map[string]Entry, error)
+}
+
+// DefaultPath returns path to default cache file.
+func DefaultPath() string {
+	dir, _ := os.UserCacheDir()
+	return filepath.Join(dir, "mdauthors.json")
+}
+
+// On-disk representation of entry.
+// Old versions stored plain strings instead of objects, such entries
+// are still accepted and get zero timestamp.
+type diskEntry struct {
+	Value string `json:"v"`
+	Time  int64  `json:"t,omitempty"`
+}
+
+func (e *diskEntry) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		*e = diskEntry{Value: str}
+		return nil
+	}
+
+	type plain diskEntry
+	return json.Unmarshal(b, (*plain)(e))
+}
+
+type fileStore struct {
+	path string
+	lock *flock.Flock
+}
+
+// NewFileStore returns store that keeps entries in JSON file.
+// Access to the file is synchronized between processes using flock.
+func NewFileStore(path string) Store {
+	return &fileStore{
+		path: path,
+		// file is replaced on every write, so we can't lock the file
+		// itself and use a separate lock file instead
+		lock: flock.New(path + ".lock"),
+	}
+}
+
+func (s *fileStore) Name() string {
+	return s.path
+}
+
+func (s *fileStore) Load() (map[string]Entry, error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %q: %w", s.path, err)
+	}
+
+	// acquire shared lock
+	if err := s.lock.RLock(); err != nil {
+		return nil, fmt.Errorf("failed to acquire shared lock on %q: %w",
+			s.lock.Path(), err)
+	}
+	defer s.lock.Unlock()
+
+	return s.read(), nil
+}
+
+func (s *fileStore) Merge(changes map[string]*Entry) (map[string]Entry, error) {
+	// acquire exclusive lock
+	if err := s.lock.Lock(); err != nil {
+		return nil, fmt.Errorf("failed to acquire exclusive lock on %q: %w",
+			s.lock.Path(), err)
+	}
+	defer s.lock.Unlock()
+
+	// re-read file under lock and apply our changes on top of it
+	entries := s.read()
+	applyChanges(entries, changes)
+
+	if err := s.write(entries); err != nil {
+		return nil, fmt.Errorf("failed to write %q: %w", s.path, err)
+	}
+
+	return entries, nil
+}
+
+// Read and parse file.
+// Must be called under lock.
+func (s *fileStore) read() map[string]Entry {
+	entries := make(map[string]Entry)
+
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return entries
+	}
+
+	var diskEntries map[string]diskEntry
+	if err := json.Unmarshal(b, &diskEntries); err != nil {
+		return entries
+	}
+
+	for key, ent := range diskEntries {
+		entries[key] = makeEntry(key, ent)
+	}
+
+	return entries
+}
+
+// Atomically replace file.
+// Must be called under exclusive lock.
+func (s *fileStore) write(entries map[string]Entry) error {
+	diskEntries := make(map[string]diskEntry, len(entries))
+	for key, e := range entries {
+		diskEntries[key] = makeDiskEntry(e)
+	}
+
+	b, err := json.MarshalIndent(diskEntries, "", " ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".mdauthors-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+type memoryStore struct {
+	entries map[string]Entry
+}
+
+// NewMemoryStore returns store that keeps entries in memory and doesn't
+// persist them anywhere.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		entries: make(map[string]Entry),
+	}
+}
+
+func (s *memoryStore) Name() string {
+	return "memory"
+}
+
+func (s *memoryStore) Load() (map[string]Entry, error) {
+	return copyEntries(s.entries), nil
+}
+
+func (s *memoryStore) Merge(changes map[string]*Entry) (map[string]Entry, error) {
+	applyChanges(s.entries, changes)
+	return copyEntries(s.entries), nil
+}
+
+func applyChanges(entries map[string]Entry, changes map[string]*Entry) {
+	for key, e := range changes {
+		if e != nil {
+			entries[key] = *e
+		} else {
+			delete(entries, key)
+		}
+	}
+}
+
+func copyEntries(entries map[string]Entry) map[string]Entry {
+	result := make(map[string]Entry, len(entries))
+	for key, e := range entries {
+		result[key] = e
+	}
+	return result
+}
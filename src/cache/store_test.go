@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFileStoreMerge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mdauthors.json")
+
+	ford := Entry{Key: "github:l2n:ix", Value: "Ford Prefect", Time: time.Unix(1000, 0)}
+	zaphod := Entry{Key: "github:l2n:zaphod", Value: "Zaphod Beeblebrox", Time: time.Unix(2000, 0)}
+	trillian := Entry{Key: "github:l2n:trillian", Value: "Tricia McMillan", Time: time.Unix(3000, 0)}
+
+	// two writers, e.g. two processes, that loaded file before any changes
+	a := NewFileStore(path)
+	b := NewFileStore(path)
+
+	for _, s := range []Store{a, b} {
+		entries, err := s.Load()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(entries) != 0 {
+			t.Fatalf("unexpected entries: %v", entries)
+		}
+	}
+
+	if _, err := a.Merge(map[string]*Entry{
+		ford.Key:   &ford,
+		zaphod.Key: &zaphod,
+	}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// b doesn't know about a's changes, but must not lose them
+	got, err := b.Merge(map[string]*Entry{
+		trillian.Key: &trillian,
+		zaphod.Key:   nil,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := map[string]Entry{
+		ford.Key:     ford,
+		trillian.Key: trillian,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected merge result:\n got: %v\nwant: %v", got, want)
+	}
+
+	got, err = NewFileStore(path).Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected file contents:\n got: %v\nwant: %v", got, want)
+	}
+
+	// temporary files are renamed or removed
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	if want := []string{"mdauthors.json", "mdauthors.json.lock"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("unexpected files in cache dir: got %q, want %q", names, want)
+	}
+}
+
+func TestFileStoreTimestamps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mdauthors.json")
+
+	// entry written by old version, without timestamp
+	if err := os.WriteFile(path, []byte(`{"github:l2n:marvin": "Marvin"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ford := Entry{Key: "github:l2n:ix", Value: "Ford Prefect", Time: time.Unix(1600000000, 0)}
+	marvin := Entry{Key: "github:l2n:marvin", Value: "Marvin"}
+
+	if _, err := NewFileStore(path).Merge(map[string]*Entry{ford.Key: &ford}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, err := NewFileStore(path).Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := map[string]Entry{
+		ford.Key:   ford,
+		marvin.Key: marvin,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected entries:\n got: %v\nwant: %v", got, want)
+	}
+
+	// export and import keep timestamps too
+	entries, err := UnmarshalEntries(MarshalEntries([]Entry{ford, marvin}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(entries, []Entry{ford, marvin}) {
+		t.Errorf("unexpected entries after export and import: %v", entries)
+	}
+}
@@ -1,25 +1,58 @@
 package defs
 
 type Config struct {
-	Format string
-	Sort   string
+	Format   string
+	Template string
+	Escape   string
+	Markers  string
+	Sort     string
+
+	// Marker name regexps with optional default attributes.
+	MarkerNames []string
 
 	Project   string
 	NoProject bool
+	Token     string
+
+	GithubHost    string
+	GithubAPI     string
+	GithubWeb     string
+	GithubRemotes []string
 
 	Append bool
 	Pipe   bool
 
+	Jobs int
+
+	AvatarSize    int
+	AvatarColumns int
+
 	Ignore []string
 }
 
 type Author struct {
 	Index int
 
-	Date  string
+	// Dates of first and last commits, "YYYY-MM-DD".
+	Date     string
+	LastDate string
+
 	Name  string
 	Email string
 
 	Login   string
 	Profile string
+	Avatar  string
+
+	Company  string
+	Website  string
+	Location string
+	Twitter  string
+	Bio      string
+
+	// Contribution types, like "code" or "doc".
+	Roles []string
+
+	// Hash of first commit.
+	Commit string
 }
@@ -0,0 +1,262 @@
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gavv/md-authors/src/backend"
+	"github.com/gavv/md-authors/src/defs"
+	"github.com/gavv/md-authors/src/logs"
+)
+
+// Config file of all-contributors bot and cli.
+// See https://allcontributors.org/docs/en/specification
+const allContribFile = ".all-contributorsrc"
+
+// Entry of "contributors" array.
+// Field order matches the one used by all-contributors tools.
+type allContribEntry struct {
+	Login         string   `json:"login"`
+	Name          string   `json:"name"`
+	AvatarURL     string   `json:"avatar_url,omitempty"`
+	Profile       string   `json:"profile"`
+	Contributions []string `json:"contributions"`
+}
+
+// Contribution type assigned to new contributors.
+const allContribDefaultRole = "code"
+
+// Create or update .all-contributorsrc file.
+func processAllContrib(data []byte, authors []defs.Author, conf defs.Config) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return createAllContrib(authors, backend.ProjectName(conf), allContribHost(conf))
+	}
+
+	return updateAllContrib(data, authors, conf.Append)
+}
+
+// Generate new .all-contributorsrc with default settings.
+func createAllContrib(authors []defs.Author, project, host string) ([]byte, error) {
+	owner, repo, _ := strings.Cut(project, "/")
+
+	keys := []string{
+		"projectName",
+		"projectOwner",
+		"repoType",
+		"repoHost",
+		"files",
+		"imageSize",
+		"commit",
+		"contributorsPerLine",
+		"contributors",
+	}
+	values := []json.RawMessage{
+		marshalJSON(repo),
+		marshalJSON(owner),
+		marshalJSON("github"),
+		marshalJSON(host),
+		marshalJSON([]string{"README.md"}),
+		marshalJSON(100),
+		marshalJSON(false),
+		marshalJSON(7),
+		marshalJSON([]any{}),
+	}
+
+	keys, values, _, err := mergeAllContrib(keys, values, authors, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return formatJSONObject(keys, values)
+}
+
+// Get value for "repoHost".
+func allContribHost(conf defs.Config) string {
+	switch {
+	case conf.GithubWeb != "":
+		return strings.TrimSuffix(conf.GithubWeb, "/")
+	case conf.GithubHost != "":
+		return "https://" + conf.GithubHost
+	default:
+		return "https://github.com"
+	}
+}
+
+// Update "contributors" array in existing .all-contributorsrc.
+//
+// Existing entries are matched with authors by login and are kept
+// as is, including contribution types. If appendMode is true, all
+// existing entries are kept, and new authors are added to the end.
+// Otherwise, array is rebuilt in the order of authors.
+//
+// Order of keys and the rest of the file is preserved.
+func updateAllContrib(data []byte, authors []defs.Author, appendMode bool) ([]byte, error) {
+	keys, values, err := parseJSONObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+
+	keys, values, changed, err := mergeAllContrib(keys, values, authors, appendMode)
+	if err != nil {
+		return nil, err
+	}
+
+	if !changed {
+		// nothing changed, keep formatting
+		return data, nil
+	}
+
+	return updateJSONObject(data, keys, values)
+}
+
+// Build new "contributors" array.
+// Returns updated keys and values, and whether array was changed.
+func mergeAllContrib(
+	keys []string, values []json.RawMessage, authors []defs.Author, appendMode bool,
+) ([]string, []json.RawMessage, bool, error) {
+	var oldEntries []json.RawMessage
+
+	idx := -1
+	for n, key := range keys {
+		if key == "contributors" {
+			idx = n
+			if err := json.Unmarshal(values[n], &oldEntries); err != nil {
+				return nil, nil, false, fmt.Errorf("invalid contributors: %w", err)
+			}
+		}
+	}
+	if idx < 0 {
+		keys = append(keys, "contributors")
+		values = append(values, nil)
+		idx = len(keys) - 1
+	}
+
+	// lower-case login => index in oldEntries
+	oldLogins := make(map[string]int)
+
+	for n, raw := range oldEntries {
+		var entry allContribEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return nil, nil, false, fmt.Errorf("invalid contributors: %w", err)
+		}
+		if _, ok := oldLogins[strings.ToLower(entry.Login)]; !ok {
+			oldLogins[strings.ToLower(entry.Login)] = n
+		}
+	}
+
+	var newEntries []json.RawMessage
+
+	if appendMode {
+		newEntries = append(newEntries, oldEntries...)
+	}
+
+	seenLogins := make(map[string]bool)
+	added := 0
+
+	for _, author := range authors {
+		if author.Login == "" {
+			logs.Debugf("skip: %s <%s>: no github login", author.Name, author.Email)
+			continue
+		}
+
+		login := strings.ToLower(author.Login)
+		if seenLogins[login] {
+			continue
+		}
+		seenLogins[login] = true
+
+		if n, ok := oldLogins[login]; ok {
+			if !appendMode {
+				newEntries = append(newEntries, oldEntries[n])
+			}
+			continue
+		}
+
+		roles := author.Roles
+		if len(roles) == 0 {
+			roles = []string{allContribDefaultRole}
+		}
+
+		logs.Infof("new: %s <%s> %s", author.Name, author.Email, author.Login)
+
+		newEntries = append(newEntries, marshalJSON(allContribEntry{
+			Login:         author.Login,
+			Name:          author.Name,
+			AvatarURL:     author.Avatar,
+			Profile:       author.Profile,
+			Contributions: roles,
+		}))
+		added++
+	}
+
+	if added != 0 {
+		logs.Infof("added %d author(s)", added)
+	}
+
+	changed := len(newEntries) != len(oldEntries)
+	for n := 0; !changed && n < len(newEntries); n++ {
+		changed = !bytes.Equal(newEntries[n], oldEntries[n])
+	}
+
+	if newEntries == nil {
+		newEntries = []json.RawMessage{}
+	}
+	values[idx] = marshalJSON(newEntries)
+
+	return keys, values, changed, nil
+}
+
+// Read contribution types from .all-contributorsrc in given
+// directory, if it exists, and assign them to authors.
+func importRoles(dir string, authors []defs.Author) error {
+	path := filepath.Join(dir, allContribFile)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("can't read %q: %w", path, err)
+	}
+
+	roles, err := parseAllContribRoles(data)
+	if err != nil {
+		return fmt.Errorf("can't parse %q: %w", path, err)
+	}
+
+	for n := range authors {
+		if authors[n].Login == "" {
+			continue
+		}
+		authors[n].Roles = roles[strings.ToLower(authors[n].Login)]
+	}
+
+	return nil
+}
+
+// Get contribution types from .all-contributorsrc, by lower-case login.
+func parseAllContribRoles(data []byte) (map[string][]string, error) {
+	var rc struct {
+		Contributors []allContribEntry `json:"contributors"`
+	}
+
+	if err := json.Unmarshal(data, &rc); err != nil {
+		return nil, err
+	}
+
+	roles := make(map[string][]string)
+
+	for _, entry := range rc.Contributors {
+		if entry.Login != "" {
+			roles[strings.ToLower(entry.Login)] = entry.Contributions
+		}
+	}
+
+	return roles, nil
+}
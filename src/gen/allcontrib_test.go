@@ -0,0 +1,64 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gavv/md-authors/src/defs"
+)
+
+func TestUpdateAllContribErrors(t *testing.T) {
+	for _, data := range []string{
+		`[]`,
+		`{"contributors": {}}`,
+		`{"contributors": [`,
+	} {
+		if _, err := updateAllContrib([]byte(data), testStructuredAuthors, false); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestImportRoles(t *testing.T) {
+	input := filepath.Join("testdata", "allcontrib.json")
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, allContribFile), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	authors := []defs.Author{
+		{Name: "Ford Prefect", Login: "IX"},
+		{Name: "Arthur Dent", Login: "sandwich-maker"},
+		{Name: "Marvin"},
+	}
+
+	if err := importRoles(dir, authors); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var roles [][]string
+	for _, author := range authors {
+		roles = append(roles, author.Roles)
+	}
+
+	want := [][]string{{"doc", "research"}, nil, nil}
+	if !reflect.DeepEqual(roles, want) {
+		t.Errorf("unexpected roles: got %q, want %q", roles, want)
+	}
+
+	spec, err := compileSpec("{name} ({roles?})")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := spec.format(authors[0]); got != "Ford Prefect (doc, research)\n" {
+		t.Errorf("unexpected result: %q", got)
+	}
+}
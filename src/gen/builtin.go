@@ -0,0 +1,77 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Predefined format specs, selected by name via --format.
+var BuiltinFormats = map[string]string{
+	// Example:
+	//  1. Ford Prefect `@Ix`
+	"modern": "{index}. {name} `{login?}`\\n",
+
+	// Example:
+	//  - Ford Prefect `Ix` (<ford@betelgeuse7.sid>)
+	"classic": "- {name} `{login?}` (<{email|profile?}>)\\n",
+
+	// Example:
+	//  |   | Name | GitHub | Since |
+	//  |---|------|--------|-------|
+	//  | <img ...> | Ford Prefect | [@Ix](https://github.com/Ix) | 2020-02-01 |
+	"table": templatePrefix +
+		`{{define "header"}}|   | Name | GitHub | Since |` + "\n" +
+		`|---|------|--------|-------|` + "\n" + `{{end}}` +
+		`| {{with .Avatar}}<img src="{{. | html}}" width="{{avatarSize}}" height="{{avatarSize}}">{{end}}` +
+		` | {{.Name | escape}}` +
+		` | {{with .Login}}[@{{.}}]({{$.Profile}}){{end}}` +
+		` | {{.Date}} |` + "\n",
+
+	// Example:
+	//  <a href="https://github.com/Ix"><img src="..." ...></a>
+	//  <a href="https://github.com/zaphod"><img src="..." ...></a><br>
+	"avatars": templatePrefix +
+		`{{if .Profile}}<a href="{{.Profile | html}}">{{end}}` +
+		`{{if .Avatar}}<img src="{{.Avatar | html}}" width="{{avatarSize}}" height="{{avatarSize}}"` +
+		` alt="{{.Login | html}}" title="{{.Name | html}}">{{else}}{{.Name | html}}{{end}}` +
+		`{{if .Profile}}</a>{{end}}` +
+		`{{if eq (mod .Index avatarColumns) 0}}<br>{{end}}` + "\n",
+
+	// Example:
+	//  <li><a href="https://github.com/Ix">Ford Prefect</a></li>
+	"html": templatePrefix +
+		`<li>{{if .Profile}}<a href="{{.Profile | html}}">{{.Name | html}}</a>` +
+		`{{else}}{{.Name | html}}{{end}}</li>` + "\n",
+
+	// Example:
+	//  1. Ford Prefect ``Ix``
+	"rst": "{index}. {name} ``{login?}``\\n",
+
+	// Example:
+	//  . Ford Prefect `Ix`
+	"asciidoc": ". {name} `{login?}`\\n",
+
+	// Example:
+	//  1. Ford Prefect =Ix=
+	"org": "{index}. {name} ={login?}=\\n",
+
+	// Continuation lines of "Copyright" field in debian/copyright.
+	// Example:
+	//             2019-2024 Ford Prefect <ford@betelgeuse7.sid>
+	"dep5": "           {years} {name} <{email?}>\\n",
+}
+
+// Resolve --format value: builtin format name, or format spec
+// or template, returned as is.
+func ResolveFormat(format string) (string, error) {
+	if strings.Contains(format, "{") || strings.HasPrefix(format, templatePrefix) {
+		return format, nil
+	}
+
+	spec, ok := BuiltinFormats[format]
+	if !ok {
+		return "", fmt.Errorf("unknown format %q", format)
+	}
+
+	return spec, nil
+}
@@ -0,0 +1,393 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gavv/md-authors/src/defs"
+	"github.com/gavv/md-authors/src/logs"
+)
+
+// Citation File Format metadata.
+// See https://citation-file-format.github.io/
+const citationFile = "CITATION.cff"
+
+// "key: value" line of mapping.
+var cffFieldRx = regexp.MustCompile(`^([\w-]+)\s*:\s*(.*)$`)
+
+// Entry of "authors" sequence.
+// Existing entries are kept as is, so we store raw lines and
+// parse only fields needed to match entry with authors.
+type cffEntry struct {
+	lines  []string
+	fields map[string]string
+}
+
+// Create or update CITATION.cff file.
+func processCitation(data []byte, authors []defs.Author, conf defs.Config) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = createCitation(projectTitle(conf))
+	}
+
+	return updateCitation(data, authors, conf.Append)
+}
+
+// Generate minimal CITATION.cff without authors.
+func createCitation(title string) []byte {
+	var b strings.Builder
+
+	b.WriteString("cff-version: 1.2.0\n")
+	b.WriteString("message: If you use this software, please cite it as below.\n")
+	b.WriteString("title: " + yamlString(title) + "\n")
+	b.WriteString("authors: []\n")
+
+	return []byte(b.String())
+}
+
+// Update "authors" sequence in CITATION.cff.
+//
+// Unlike markdown files, existing entries are matched with authors
+// by alias (login), email, or name, and matched entries are kept as
+// is, including fields we don't know about, like orcid.
+//
+// If append is true, all existing entries are kept, and new authors
+// are added to the end. Otherwise, sequence is rebuilt in the order
+// of authors, and entries not matching any author are removed.
+//
+// Formatting of the rest of the file is preserved.
+func updateCitation(data []byte, authors []defs.Author, appendMode bool) ([]byte, error) {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+
+	keyLine, blockEnd := -1, -1
+	isEmpty := false
+
+	for n, line := range lines {
+		m := cffFieldRx.FindStringSubmatch(line)
+		if m == nil || m[1] != "authors" {
+			continue
+		}
+		switch value := yamlValue(m[2]); strings.ReplaceAll(value, " ", "") {
+		case "":
+		case "[]":
+			isEmpty = true
+		default:
+			return nil, fmt.Errorf("unsupported format of authors at line %d", n+1)
+		}
+		keyLine, blockEnd = n, n+1
+		break
+	}
+
+	if keyLine >= 0 && !isEmpty {
+		for n := keyLine + 1; n < len(lines); n++ {
+			line := lines[n]
+			if trimmed := strings.TrimSpace(line); trimmed == "" || trimmed[0] == '#' {
+				continue
+			}
+			if line[0] != ' ' && line[0] != '-' {
+				break
+			}
+			blockEnd = n + 1
+		}
+	}
+
+	var (
+		indent   = "  "
+		preamble []string
+		entries  []cffEntry
+	)
+
+	if keyLine >= 0 {
+		var err error
+		indent, preamble, entries, err = parseCffEntries(lines[keyLine+1:blockEnd], keyLine+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	newEntries := mergeCffEntries(entries, authors, indent, appendMode)
+
+	if keyLine >= 0 && !isEmpty && equalCffEntries(entries, newEntries) {
+		// nothing changed, keep formatting
+		return data, nil
+	}
+	if isEmpty && len(newEntries) == 0 {
+		return data, nil
+	}
+
+	var block []string
+
+	if len(newEntries) == 0 {
+		block = append(block, "authors: []")
+	} else {
+		block = append(block, "authors:")
+		block = append(block, preamble...)
+		for _, entry := range newEntries {
+			block = append(block, entry.lines...)
+		}
+	}
+
+	var result []string
+
+	if keyLine >= 0 {
+		result = append(result, lines[:keyLine]...)
+		result = append(result, block...)
+		result = append(result, lines[blockEnd:]...)
+	} else {
+		result = append(result, lines...)
+		result = append(result, block...)
+	}
+
+	return []byte(strings.Join(result, "\n") + "\n"), nil
+}
+
+// Split lines of "authors" block into entries.
+// Returns indentation of entries, and lines before first entry.
+func parseCffEntries(lines []string, lineNo int) (string, []string, []cffEntry, error) {
+	var (
+		indent   string
+		preamble []string
+		entries  []cffEntry
+	)
+
+	indent = "  "
+	for _, line := range lines {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && trimmed[0] != '#' {
+			indent = line[:len(line)-len(strings.TrimLeft(line, " "))]
+			break
+		}
+	}
+
+	for n, line := range lines {
+		rest, isItem := strings.CutPrefix(line, indent+"-")
+		isItem = isItem && (rest == "" || rest[0] == ' ')
+
+		if isItem {
+			entries = append(entries, cffEntry{})
+		} else if len(entries) == 0 {
+			if trimmed := strings.TrimSpace(line); trimmed != "" && trimmed[0] != '#' {
+				return "", nil, nil,
+					fmt.Errorf("unsupported format of authors at line %d", lineNo+n+1)
+			}
+			preamble = append(preamble, line)
+			continue
+		}
+
+		entry := &entries[len(entries)-1]
+		entry.lines = append(entry.lines, line)
+
+		// first line is "- key: value", others are "  key: value"
+		field := strings.TrimPrefix(line, indent)
+		if isItem {
+			field = strings.TrimLeft(rest, " ")
+		} else if !strings.HasPrefix(field, "  ") || strings.HasPrefix(field, "   ") {
+			continue
+		}
+		if m := cffFieldRx.FindStringSubmatch(strings.TrimSpace(field)); m != nil {
+			if entry.fields == nil {
+				entry.fields = make(map[string]string)
+			}
+			entry.fields[m[1]] = yamlValue(m[2])
+		}
+	}
+
+	return indent, preamble, entries, nil
+}
+
+// Get lower-case alias, email, and full name of entry.
+func (e *cffEntry) matchKeys() []string {
+	var keys []string
+
+	for _, key := range []string{
+		e.fields["alias"],
+		e.fields["email"],
+		e.fields["name"],
+		joinName(e.fields["given-names"], e.fields["name-particle"], e.fields["family-names"]),
+	} {
+		if key != "" {
+			keys = append(keys, strings.ToLower(key))
+		}
+	}
+
+	return keys
+}
+
+// Build new list of entries.
+func mergeCffEntries(
+	entries []cffEntry, authors []defs.Author, indent string, appendMode bool,
+) []cffEntry {
+	var result []cffEntry
+
+	if appendMode {
+		result = append(result, entries...)
+	}
+
+	used := make([]bool, len(entries))
+	added := 0
+
+	for _, author := range uniqueAuthors(authors) {
+		if author.Name == "" && author.Login == "" {
+			logs.Debugf("skip: <%s>: no name", author.Email)
+			continue
+		}
+
+		idx := findCffEntry(entries, used, author)
+		if idx >= 0 {
+			used[idx] = true
+			if !appendMode {
+				result = append(result, entries[idx])
+			}
+			continue
+		}
+
+		logs.Infof("new: %s <%s> %s", author.Name, author.Email, author.Login)
+
+		result = append(result, formatCffEntry(author, indent))
+		added++
+	}
+
+	if added != 0 {
+		logs.Infof("added %d author(s)", added)
+	}
+
+	return result
+}
+
+// Find unused entry matching author.
+func findCffEntry(entries []cffEntry, used []bool, author defs.Author) int {
+	var keys []string
+
+	for _, key := range []string{author.Login, author.Email, joinName(author.Name)} {
+		if key != "" {
+			keys = append(keys, strings.ToLower(key))
+		}
+	}
+
+	for n, entry := range entries {
+		if used[n] {
+			continue
+		}
+		for _, entryKey := range entry.matchKeys() {
+			for _, key := range keys {
+				if entryKey == key {
+					return n
+				}
+			}
+		}
+	}
+
+	return -1
+}
+
+// Format new entry for author.
+func formatCffEntry(author defs.Author, indent string) cffEntry {
+	var fields [][2]string
+
+	given, particle, family := splitName(author.Name)
+
+	for _, field := range [][2]string{
+		{"given-names", given},
+		{"name-particle", particle},
+		{"family-names", family},
+		{"email", author.Email},
+		{"alias", author.Login},
+	} {
+		if field[1] != "" {
+			fields = append(fields, field)
+		}
+	}
+
+	var entry cffEntry
+
+	for n, field := range fields {
+		prefix := indent + "  "
+		if n == 0 {
+			prefix = indent + "- "
+		}
+		entry.lines = append(entry.lines, prefix+field[0]+": "+yamlString(field[1]))
+	}
+
+	return entry
+}
+
+func equalCffEntries(a, b []cffEntry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for n := range a {
+		if strings.Join(a[n].lines, "\n") != strings.Join(b[n].lines, "\n") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Join non-empty name parts with single spaces.
+func joinName(parts ...string) string {
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// Format string as YAML scalar.
+// Plain scalar is used when it's safe, and double-quoted otherwise.
+func yamlString(s string) string {
+	isPlain := s != "" && s == strings.TrimSpace(s)
+
+	for n, r := range s {
+		if !isPlain {
+			break
+		}
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+		case n != 0 && strings.ContainsRune(" .,_-+@/()'", r):
+		default:
+			isPlain = false
+		}
+	}
+
+	if isPlain {
+		switch strings.ToLower(s) {
+		case "true", "false", "yes", "no", "on", "off", "null":
+			isPlain = false
+		}
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			isPlain = false
+		}
+	}
+
+	if isPlain {
+		return s
+	}
+
+	// json string is also a valid yaml double-quoted scalar
+	return string(marshalJSON(s))
+}
+
+// Parse YAML scalar value, plain or quoted.
+func yamlValue(s string) string {
+	s = strings.TrimSpace(s)
+
+	switch {
+	case strings.HasPrefix(s, `"`):
+		if end := strings.LastIndex(s, `"`); end > 0 {
+			if value, err := strconv.Unquote(s[:end+1]); err == nil {
+				return value
+			}
+			return s[1:end]
+		}
+	case strings.HasPrefix(s, `'`):
+		if end := strings.LastIndex(s, `'`); end > 0 {
+			return strings.ReplaceAll(s[1:end], `''`, `'`)
+		}
+	}
+
+	if pos := strings.Index(s, " #"); pos >= 0 {
+		s = strings.TrimSpace(s[:pos])
+	}
+
+	return s
+}
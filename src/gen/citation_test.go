@@ -0,0 +1,44 @@
+package gen
+
+import (
+	"testing"
+)
+
+func TestUpdateCitationErrors(t *testing.T) {
+	for _, data := range []string{
+		"authors: [{name: Marvin}]\n",
+		"authors:\n  name: Marvin\n",
+	} {
+		if _, err := updateCitation([]byte(data), testStructuredAuthors, false); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestYAMLString(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Arthur", "Arthur"},
+		{"O'Brien", "O'Brien"},
+		{"ford@betelgeuse7.sid", "ford@betelgeuse7.sid"},
+		{"Zoë", "Zoë"},
+		{"", `""`},
+		{"42", `"42"`},
+		{"yes", `"yes"`},
+		{"-dash", `"-dash"`},
+		{"a: b", `"a: b"`},
+		{"a #b", `"a #b"`},
+		{` quote"`, `" quote\""`},
+	}
+
+	for _, tt := range tests {
+		if got := yamlString(tt.value); got != tt.want {
+			t.Errorf("yamlString(%q): got %s, want %s", tt.value, got, tt.want)
+		}
+		if got := yamlValue(yamlString(tt.value)); got != tt.value {
+			t.Errorf("yamlValue(yamlString(%q)): got %q", tt.value, got)
+		}
+	}
+}
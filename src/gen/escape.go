@@ -0,0 +1,300 @@
+package gen
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Escaping modes of field values (--escape option).
+const (
+	escapeAuto     = "auto"
+	escapeMarkdown = "markdown"
+	escapeHTML     = "html"
+	escapeRST      = "rst"
+	escapeAsciidoc = "asciidoc"
+	escapeOrg      = "org"
+	escapeNone     = "none"
+)
+
+// Context of field in format spec.
+type escapeContext int
+
+const (
+	// Regular markdown text.
+	contextText escapeContext = iota
+	// Inside `code span`.
+	contextCode
+	// Whole content of `code span`, i.e. field is enclosed
+	// in backticks without other text.
+	contextCodeSpan
+	// Inside <...> before any whitespace, i.e. autolink.
+	contextAutolink
+	// Inside <...> after whitespace, i.e. html tag attributes.
+	contextTag
+	// Inside verbatim text, like ``literal`` in rst or =verbatim=
+	// in org, where markup is not interpreted and can't be escaped.
+	contextVerbatim
+)
+
+// Resolve escaping mode.
+// In auto mode, markdown is escaped in files, and nothing in --pipe mode.
+func resolveEscape(mode string, pipe bool) (string, error) {
+	switch mode {
+	case "", escapeAuto:
+		if pipe {
+			return escapeNone, nil
+		}
+		return escapeMarkdown, nil
+	case escapeMarkdown, escapeHTML, escapeRST, escapeAsciidoc, escapeOrg, escapeNone:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown escape mode %q", mode)
+	}
+}
+
+// Escape field value according to mode and context.
+func escapeValue(mode string, context escapeContext, name string, value string) string {
+	switch mode {
+	case escapeMarkdown:
+		switch context {
+		case contextCode:
+			// escapes don't work inside code spans, and
+			// backtick would terminate the span
+			return strings.ReplaceAll(value, "`", "\u02CB")
+		case contextCodeSpan:
+			return widenCodeSpan(value)
+		case contextAutolink:
+			// entities are not decoded in autolinks
+			return autolinkEscape(value)
+		case contextTag:
+			return html.EscapeString(value)
+		}
+		if isLinkField(name) {
+			// backslashes would break links
+			return value
+		}
+		return markdownEscape(value)
+
+	case escapeHTML:
+		return html.EscapeString(value)
+
+	case escapeRST:
+		if context == contextVerbatim || isLinkField(name) {
+			return value
+		}
+		return rstEscape(value)
+
+	case escapeAsciidoc:
+		if isLinkField(name) {
+			return value
+		}
+		return asciidocEscape(value)
+
+	case escapeOrg:
+		if context == contextVerbatim || isLinkField(name) {
+			return value
+		}
+		return orgEscape(value)
+	}
+
+	return value
+}
+
+// Check if field contains url or email.
+func isLinkField(name string) bool {
+	switch name {
+	case "email", "profile", "avatar", "website":
+		return true
+	}
+
+	return false
+}
+
+// Update context according to literal text of spec.
+// Rules depend on markup of escaping mode.
+func updateContext(mode string, context escapeContext, text string) escapeContext {
+	switch mode {
+	case escapeRST:
+		return updateContextRST(context, text)
+	case escapeOrg:
+		return updateContextOrg(context, text)
+	}
+
+	return updateContextMarkdown(context, text)
+}
+
+// Track code spans, autolinks, and html tags.
+func updateContextMarkdown(context escapeContext, text string) escapeContext {
+	for _, c := range text {
+		switch {
+		case c == '\n':
+			context = contextText
+		case c == '`' && (context == contextText || context == contextCode):
+			if context == contextCode {
+				context = contextText
+			} else {
+				context = contextCode
+			}
+		case c == '<' && context == contextText:
+			context = contextAutolink
+		case (c == ' ' || c == '\t') && context == contextAutolink:
+			context = contextTag
+		case c == '>' && (context == contextAutolink || context == contextTag):
+			context = contextText
+		}
+	}
+
+	return context
+}
+
+// Track inline literals, i.e. text between double backticks.
+func updateContextRST(context escapeContext, text string) escapeContext {
+	for len(text) > 0 {
+		switch {
+		case text[0] == '\n':
+			context = contextText
+		case strings.HasPrefix(text, "``"):
+			if context == contextVerbatim {
+				context = contextText
+			} else {
+				context = contextVerbatim
+			}
+			text = text[2:]
+			continue
+		}
+		text = text[1:]
+	}
+
+	return context
+}
+
+// Track =verbatim= text. Opening marker is recognized only at
+// the beginning of text, or after whitespace or opening punctuation.
+func updateContextOrg(context escapeContext, text string) escapeContext {
+	prev := ' '
+
+	for _, c := range text {
+		switch {
+		case c == '\n':
+			context = contextText
+		case c == '=' && context == contextVerbatim:
+			context = contextText
+		case c == '=' && (unicode.IsSpace(prev) || strings.ContainsRune(`-('"{`, prev)):
+			context = contextVerbatim
+		}
+		prev = c
+	}
+
+	return context
+}
+
+// Escape characters that have special meaning in markdown inline text.
+func markdownEscape(text string) string {
+	var b strings.Builder
+
+	for _, c := range text {
+		switch c {
+		case '\\', '`', '*', '_', '[', ']', '<', '>', '|', '#':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// Make value safe to be the whole content of a code span, which is
+// enclosed in single backticks by spec. If value has backticks, adds
+// backticks and spaces around it, so that resulting fence is longer
+// than any backtick run inside value. Spaces are stripped by renderer.
+func widenCodeSpan(value string) string {
+	longest, run := 0, 0
+	for _, c := range value {
+		if c == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+
+	if longest == 0 {
+		return value
+	}
+
+	fence := strings.Repeat("`", longest)
+
+	return fence + " " + value + " " + fence
+}
+
+// Percent-encode characters that would terminate autolink.
+func autolinkEscape(value string) string {
+	return autolinkReplacer.Replace(value)
+}
+
+var autolinkReplacer = strings.NewReplacer(
+	" ", "%20",
+	"<", "%3C",
+	">", "%3E",
+)
+
+// Characters that can start inline markup, roles, references,
+// or substitutions in reStructuredText.
+const rstSpecial = "\\*`_|"
+
+// Escape characters that have special meaning in rst inline text.
+func rstEscape(text string) string {
+	var b strings.Builder
+
+	for _, c := range text {
+		if strings.ContainsRune(rstSpecial, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// Characters that can start inline formatting, macros, cross
+// references, or attribute references in AsciiDoc.
+const asciidocSpecial = "*_`#^~[]{}+<>\\"
+
+// Wrap text into passthrough, if it contains AsciiDoc special
+// characters. Passthroughs disable everything except substitution
+// of characters like < and &, which is still needed for html output.
+func asciidocEscape(text string) string {
+	if !strings.ContainsAny(text, asciidocSpecial) {
+		return text
+	}
+
+	if !strings.Contains(text, "+") {
+		// constrained passthrough
+		return "+" + text + "+"
+	}
+
+	// plus would terminate passthrough, use macro instead
+	return "pass:c[" + strings.ReplaceAll(text, "]", "\\]") + "]"
+}
+
+// Characters used by Org for emphasis, links, and macros.
+const orgSpecial = "*/_=~+[]{}"
+
+// Insert zero width space after Org markup characters.
+// Org has no general escape character, and zero width space is
+// the way recommended by its manual to prevent text that looks
+// like markup from being interpreted.
+func orgEscape(text string) string {
+	var b strings.Builder
+
+	for _, c := range text {
+		b.WriteRune(c)
+		if strings.ContainsRune(orgSpecial, c) {
+			b.WriteString("\u200B")
+		}
+	}
+
+	return b.String()
+}
@@ -4,10 +4,110 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/gavv/md-authors/src/defs"
 )
 
+// Compiled --format or --template.
+type formatter interface {
+	// Count entries in existing block content (for --append).
+	// Authors is the full list of known authors.
+	countEntries(content string, authors []defs.Author) int
+
+	// Format list of new authors.
+	// Returns text to be inserted into block content, and position
+	// where it should be inserted.
+	formatAuthors(content string, authors []defs.Author) (int, string, error)
+}
+
+// Compile format spec or template from config.
+func compileFormat(conf defs.Config) (formatter, error) {
+	escape, err := resolveEscape(conf.Escape, conf.Pipe)
+	if err != nil {
+		return nil, err
+	}
+	conf.Escape = escape
+
+	if conf.Template != "" {
+		return compileTemplate(conf.Template, conf)
+	}
+
+	if tmpl, ok := strings.CutPrefix(conf.Format, templatePrefix); ok {
+		return compileTemplate(tmpl, conf)
+	}
+
+	spec, err := compileSpec(conf.Format)
+	if err != nil {
+		return nil, err
+	}
+	spec.setEscape(escape)
+
+	return spec, nil
+}
+
+// Compiled format spec.
+//
+// Format spec grammar (EBNF):
+//
+//	spec     = { literal | escape | field } .
+//	literal  = char { char } .            (any chars except "\" and "{")
+//	escape   = "\" char .
+//	field    = "{" name { "|" name } { ":" modifier } [ "?" ] "}" .
+//	name     = namechar { namechar } .    (letters, digits, "_")
+//	modifier = name [ "=" number ] | string .
+//	string   = go double-quoted string .
+//
+// Escapes are unquoted using Go rules (\n, \t, etc.), and other escaped
+// characters are taken as is (\{, \\, etc.). A single "\" at the very end
+// of spec is a literal backslash.
+//
+// Modifiers are applied to the first non-empty alternative, from left
+// to right. A string modifier is a date layout.
+//
+// Spec is compiled into a list of nodes, where adjacent literals and
+// escapes are merged into a single literal node.
+//
+// Field values are escaped according to escape mode and field context,
+// which is determined by preceding literal text.
+type formatSpec struct {
+	nodes  []specNode
+	escape string
+}
+
+// Node of compiled spec: either literal text or field.
+type specNode struct {
+	text  string
+	field *specField
+}
+
+// Field expression in curly braces.
+// Names are alternatives, first non-empty is used.
+type specField struct {
+	names     []string
+	modifiers []specModifier
+	squash    bool
+	context   escapeContext
+}
+
+// Modifier of field value, like "upper" or "pad=10".
+// If layout is set, it's a date layout.
+type specModifier struct {
+	name   string
+	arg    int
+	layout string
+}
+
+// Supported modifiers, and whether they require numeric argument.
+var specModifiers = map[string]bool{
+	"upper":     false,
+	"lower":     false,
+	"pad":       true,
+	"lpad":      true,
+	"trunc":     true,
+	"obfuscate": false,
+}
+
 type squashStep int
 
 const (
@@ -18,87 +118,421 @@ const (
 	squashWsAfter
 )
 
-// Format author to string according to conf.format template.
-// Substitutes patterns like:
-//   - {email}
-//   - {email?}
-//   - {email|profile}
-//   - {email|profile?}
-//
-// ..with corresponding fields of author struct.
-func formatAuthor(author defs.Author, conf defs.Config) (string, error) {
-	fstr := conf.Format
+// Compile format spec into a list of nodes.
+func compileSpec(fstr string) (*formatSpec, error) {
+	spec := &formatSpec{}
 	fpos := 0
 
-	var (
-		result           string
-		curText          string
-		curIsLiteral     bool
-		trailingLiterals int
-		squashState      squashStep = dontSquash
-	)
+	addText := func(text string) {
+		if n := len(spec.nodes); n > 0 && spec.nodes[n-1].field == nil {
+			spec.nodes[n-1].text += text
+		} else {
+			spec.nodes = append(spec.nodes, specNode{text: text})
+		}
+	}
 
 	for fpos < len(fstr) {
 		switch {
 		case fstr[fpos] == '\\' && fpos < len(fstr)-1:
 			// get escaped character like \n
-			curText = fstr[fpos : fpos+2]
-			curIsLiteral = true
-			fpos += 2
+			_, size := utf8.DecodeRuneInString(fstr[fpos+1:])
+			escape := fstr[fpos : fpos+1+size]
+			fpos += 1 + size
 
 			// unescape using go syntax rules
-			rune, _, _, err := strconv.UnquoteChar(curText, 0)
-			if err == nil {
-				curText = string(rune)
+			r, _, tail, err := strconv.UnquoteChar(escape, 0)
+			if err == nil && tail == "" {
+				addText(string(r))
 			} else {
-				// on error, copy second character as is
-				curText = curText[1:]
+				// on error, copy escaped character as is
+				addText(escape[1:])
 			}
 
 		case fstr[fpos] == '{':
-			fpos++
-
 			// find expression in curly braces
+			end := findFieldEnd(fstr[fpos:])
+			if end < 0 {
+				return nil, fmt.Errorf("bad format spec: missing trailing `}'")
+			}
+
+			field, err := compileField(fstr[fpos+1 : fpos+end])
+			if err != nil {
+				return nil, err
+			}
+			spec.nodes = append(spec.nodes, specNode{field: field})
+			fpos += end + 1
+
+		default:
+			// skip until backslash or curly brace
 			end := fpos + 1
-			for end < len(fstr) && fstr[end] != '}' {
+			for end < len(fstr) && fstr[end] != '\\' && fstr[end] != '{' {
 				end++
 			}
-			if end == len(fstr) {
-				return "", fmt.Errorf("bad format spec: missing trailing `}'")
+
+			// copy text as is
+			addText(fstr[fpos:end])
+			fpos = end
+		}
+	}
+
+	return spec, nil
+}
+
+// Set escaping mode, and determine context of each field by
+// preceding literal text, according to markup of the mode.
+func (spec *formatSpec) setEscape(mode string) {
+	spec.escape = mode
+
+	context := contextText
+
+	for _, node := range spec.nodes {
+		if node.field == nil {
+			context = updateContext(mode, context, node.text)
+		} else {
+			node.field.context = context
+		}
+	}
+
+	// field enclosed in backticks can be escaped by widening the span
+	for n, node := range spec.nodes {
+		if node.field == nil || node.field.context != contextCode ||
+			n == 0 || n == len(spec.nodes)-1 {
+			continue
+		}
+		if strings.HasSuffix(spec.nodes[n-1].text, "`") &&
+			strings.HasPrefix(spec.nodes[n+1].text, "`") {
+			node.field.context = contextCodeSpan
+		}
+	}
+}
+
+// Find closing curly brace, skipping quoted strings.
+func findFieldEnd(str string) int {
+	for pos := 0; pos < len(str); pos++ {
+		switch str[pos] {
+		case '}':
+			return pos
+		case '"':
+			quoted, err := strconv.QuotedPrefix(str[pos:])
+			if err != nil {
+				return -1
 			}
+			pos += len(quoted) - 1
+		}
+	}
+
+	return -1
+}
 
-			// evaluate expression and squash flag
-			field, squash, err := evalExpr(fstr[fpos:end], author)
+// Compile expression inside curly braces.
+func compileField(expr string) (*specField, error) {
+	field := &specField{}
+
+	if strings.HasSuffix(expr, "?") {
+		field.squash = true
+		expr = expr[:len(expr)-1]
+	}
+
+	if expr == "" {
+		return nil, fmt.Errorf("bad format spec: empty field")
+	}
+
+	// names can't contain quotes, so first colon ends names
+	expr, modifiers, hasModifiers := strings.Cut(expr, ":")
+
+	if hasModifiers {
+		for {
+			mod, rest, err := compileModifier(modifiers)
 			if err != nil {
-				return "", err
+				return nil, err
+			}
+			field.modifiers = append(field.modifiers, mod)
+
+			if rest == "" {
+				break
+			}
+			modifiers = rest[1:]
+		}
+	}
+
+	for _, name := range strings.Split(expr, "|") {
+		if !isFieldName(name) {
+			return nil, fmt.Errorf("bad format spec: invalid field `%s'", name)
+		}
+		if _, err := getField(name, defs.Author{}); err != nil {
+			return nil, err
+		}
+		field.names = append(field.names, name)
+	}
+
+	return field, nil
+}
+
+// Compile modifier at the beginning of string.
+// Returns modifier and the rest of string, starting from next colon.
+func compileModifier(str string) (specModifier, string, error) {
+	var mod specModifier
+
+	if strings.HasPrefix(str, "\"") {
+		quoted, err := strconv.QuotedPrefix(str)
+		if err != nil {
+			return mod, "", fmt.Errorf("bad format spec: invalid modifier `%s'", str)
+		}
+		mod.layout, _ = strconv.Unquote(quoted)
+
+		rest := str[len(quoted):]
+		if rest != "" && rest[0] != ':' {
+			return mod, "", fmt.Errorf("bad format spec: invalid modifier `%s'", str)
+		}
+		return mod, rest, nil
+	}
+
+	token, rest := str, ""
+	if i := strings.IndexByte(str, ':'); i >= 0 {
+		token, rest = str[:i], str[i:]
+	}
+
+	name, arg, hasArg := strings.Cut(token, "=")
+
+	needArg, ok := specModifiers[name]
+	if !ok {
+		if !isFieldName(name) {
+			return mod, "", fmt.Errorf("bad format spec: invalid modifier `%s'", token)
+		}
+		return mod, "", fmt.Errorf("bad format spec: unknown modifier `%s'", name)
+	}
+	if needArg != hasArg {
+		return mod, "", fmt.Errorf("bad format spec: invalid modifier `%s'", token)
+	}
+
+	mod.name = name
+
+	if hasArg {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 || strconv.Itoa(n) != arg {
+			return mod, "", fmt.Errorf("bad format spec: invalid modifier `%s'", token)
+		}
+		mod.arg = n
+	}
+
+	return mod, rest, nil
+}
+
+// String returns modifier in canonical form.
+func (mod specModifier) String() string {
+	switch {
+	case mod.name == "":
+		return strconv.Quote(mod.layout)
+	case specModifiers[mod.name]:
+		return mod.name + "=" + strconv.Itoa(mod.arg)
+	default:
+		return mod.name
+	}
+}
+
+// Apply modifier to field value.
+// Width modifiers (pad, lpad, trunc) are handled by formatValue().
+func (mod specModifier) apply(value string) string {
+	switch mod.name {
+	case "":
+		return formatDate(mod.layout, value)
+	case "upper":
+		return strings.ToUpper(value)
+	case "lower":
+		return strings.ToLower(value)
+	case "obfuscate":
+		return obfuscateText(value)
+	}
+
+	return value
+}
+
+// Apply modifiers to field value and escape it.
+//
+// Width modifiers measure escaped value, so that padding aligns
+// columns of the resulting text, and truncation removes whole
+// characters of unescaped value, so that escape sequences are
+// never cut in half. Other modifiers are applied before escaping.
+func (spec *formatSpec) formatValue(field *specField, name string, value string) string {
+	// value is kept unescaped, and padding is tracked separately
+	lpad, rpad := 0, 0
+
+	width := func() int {
+		escaped := escapeValue(spec.escape, field.context, name, value)
+		return lpad + utf8.RuneCountInString(escaped) + rpad
+	}
+
+	for _, mod := range field.modifiers {
+		switch mod.name {
+		case "pad":
+			rpad += max(0, mod.arg-width())
+		case "lpad":
+			lpad += max(0, mod.arg-width())
+		case "trunc":
+			// cut from the end: right padding, value, left padding
+			rpad -= min(rpad, max(0, width()-mod.arg))
+			for value != "" && width() > mod.arg {
+				_, size := utf8.DecodeLastRuneInString(value)
+				value = value[:len(value)-size]
 			}
-			fpos = end + 1
+			lpad = min(lpad, mod.arg)
+		default:
+			value = mod.apply(value)
+		}
+	}
+
+	return strings.Repeat(" ", lpad) +
+		escapeValue(spec.escape, field.context, name, value) +
+		strings.Repeat(" ", rpad)
+}
+
+// Obfuscate email, e.g. "ford at betelgeuse7 dot sid".
+func obfuscateText(text string) string {
+	text = strings.ReplaceAll(text, "@", " at ")
+	text = strings.ReplaceAll(text, ".", " dot ")
 
-			curText = field
+	return text
+}
+
+func isFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') &&
+			!(c >= '0' && c <= '9') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns spec in canonical form.
+// Compiling the returned string gives the same spec.
+func (spec *formatSpec) String() string {
+	var b strings.Builder
+
+	for _, node := range spec.nodes {
+		if node.field == nil {
+			b.WriteString(escapeText(node.text))
+			continue
+		}
+
+		b.WriteString("{")
+		b.WriteString(strings.Join(node.field.names, "|"))
+		for _, mod := range node.field.modifiers {
+			b.WriteString(":")
+			b.WriteString(mod.String())
+		}
+		if node.field.squash {
+			b.WriteString("?")
+		}
+		b.WriteString("}")
+	}
+
+	return b.String()
+}
+
+// Escape literal text so that it can be used in spec.
+func escapeText(text string) string {
+	var b strings.Builder
+
+	for len(text) > 0 {
+		c, size := utf8.DecodeRuneInString(text)
+		if c == utf8.RuneError && size == 1 {
+			// keep invalid bytes as is
+			b.WriteByte(text[0])
+			text = text[1:]
+			continue
+		}
+		text = text[size:]
+
+		switch c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '{':
+			b.WriteString(`\{`)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\v':
+			b.WriteString(`\v`)
+		default:
+			b.WriteRune(c)
+		}
+	}
+
+	return b.String()
+}
+
+// Count entries in existing block content.
+// Assume that 1 author = 1 non-blank line.
+func (spec *formatSpec) countEntries(content string, authors []defs.Author) int {
+	return countLines(content)
+}
+
+// Format list of authors, one by one, and append them to the end.
+func (spec *formatSpec) formatAuthors(
+	content string, authors []defs.Author,
+) (int, string, error) {
+	var b strings.Builder
+
+	for _, author := range authors {
+		b.WriteString(spec.format(author))
+	}
+
+	return len(content), b.String(), nil
+}
+
+// Format author to string according to compiled spec.
+// Substitutes fields like:
+//   - {email}
+//   - {email?}
+//   - {email|profile}
+//   - {email|profile?}
+//
+// ..with corresponding fields of author struct.
+//
+// If field with "?" is empty, adjacent non-whitespace literal characters
+// are removed, and surrounding whitespace is squashed into one.
+func (spec *formatSpec) format(author defs.Author) string {
+	var (
+		result           string
+		curText          string
+		curIsLiteral     bool
+		trailingLiterals int
+		squashState      squashStep = dontSquash
+	)
+
+	for _, node := range spec.nodes {
+		if node.field == nil {
+			curText = node.text
+			curIsLiteral = true
+		} else {
+			name, value := evalField(node.field, author)
+			curText = value
 			curIsLiteral = false
 
 			// decide whether we need to squash
-			if curText != "" {
+			if curText != "" || !node.field.squash {
 				squashState = dontSquash
 			} else {
-				if !squash {
-					squashState = dontSquash
-				} else {
-					squashState = squashNonWsBefore
-				}
+				squashState = squashNonWsBefore
 			}
 
-		default:
-			// skip until curly brace
-			end := fpos + 1
-			for end < len(fstr) && fstr[end] != '\\' && fstr[end] != '{' {
-				end++
+			// modifiers are not applied if field is squashed
+			if squashState == dontSquash {
+				curText = spec.formatValue(node.field, name, curText)
 			}
-
-			// copy text as is
-			curText = fstr[fpos:end]
-			curIsLiteral = true
-			fpos = end
 		}
 
 		// step 1: remove adjustent non-whitespaces before field
@@ -178,32 +612,21 @@ func formatAuthor(author defs.Author, conf defs.Config) (string, error) {
 		result += "\n"
 	}
 
-	return result, nil
+	return result
 }
 
-// Evaluates expression inside curly braces.
-// See formatAuthor().
-// Returns evaluated result and squash flag.
-func evalExpr(expr string, author defs.Author) (string, bool, error) {
-	squash := false
-	if strings.HasSuffix(expr, "?") {
-		squash = true
-		expr = expr[:len(expr)-1]
-	}
-
-	field := ""
-	for _, subexpr := range strings.Split(expr, "|") {
-		var err error
-		field, err = getField(subexpr, author)
-		if err != nil {
-			return "", false, err
-		}
-		if field != "" {
-			break
+// Evaluates field expression.
+// Returns name and value of first non-empty alternative.
+func evalField(field *specField, author defs.Author) (string, string) {
+	for _, name := range field.names {
+		// names are validated during compilation
+		value, _ := getField(name, author)
+		if value != "" {
+			return name, value
 		}
 	}
 
-	return field, squash, nil
+	return "", ""
 }
 
 // Get value of author's field by name.
@@ -215,6 +638,10 @@ func getField(name string, author defs.Author) (string, error) {
 		result = fmt.Sprint(author.Index)
 	case "date":
 		result = author.Date
+	case "lastdate":
+		result = author.LastDate
+	case "years":
+		result = formatYears(author.Date, author.LastDate)
 	case "name":
 		result = author.Name
 	case "email":
@@ -223,6 +650,20 @@ func getField(name string, author defs.Author) (string, error) {
 		result = author.Login
 	case "profile":
 		result = author.Profile
+	case "avatar":
+		result = author.Avatar
+	case "company":
+		result = author.Company
+	case "website":
+		result = author.Website
+	case "location":
+		result = author.Location
+	case "twitter":
+		result = author.Twitter
+	case "bio":
+		result = author.Bio
+	case "roles":
+		result = strings.Join(author.Roles, ", ")
 	default:
 		return "", fmt.Errorf("bad format spec: unknown field `%s'", name)
 	}
@@ -230,6 +671,19 @@ func getField(name string, author defs.Author) (string, error) {
 	return strings.TrimSpace(result), nil
 }
 
+// Format range of years of contribution, like "2019-2024",
+// or just "2019" if both dates are in the same year.
+func formatYears(firstDate, lastDate string) string {
+	first, _, _ := strings.Cut(firstDate, "-")
+	last, _, _ := strings.Cut(lastDate, "-")
+
+	if last == "" || last == first {
+		return first
+	}
+
+	return first + "-" + last
+}
+
 func isSpace(c byte) bool {
 	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
 }
@@ -0,0 +1,336 @@
+package gen
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gavv/md-authors/src/defs"
+)
+
+var testAuthor = defs.Author{
+	Index:    42,
+	Date:     "2020-01-01",
+	LastDate: "2023-05-01",
+	Name:     "Arthur Dent",
+	Email:    "dent@yahoo.com",
+	Login:    "",
+	Profile:  "",
+}
+
+func TestFormatSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		// plain fields
+		{"{index}. {name}\\n", "42. Arthur Dent\n"},
+		{"{name}", "Arthur Dent\n"},
+		{"", "\n"},
+		// alternatives
+		{"{login|email}", "dent@yahoo.com\n"},
+		{"{login|profile|name}", "Arthur Dent\n"},
+		{"{login|profile}", "\n"},
+		// squash
+		{"{name} `{login?}` <{email}>", "Arthur Dent <dent@yahoo.com>\n"},
+		{"{name} (<{login|profile?}>)\\n", "Arthur Dent\n"},
+		{"{name}  [{login?}]  {date}", "Arthur Dent 2020-01-01\n"},
+		{"[{login?}] {name}", " Arthur Dent\n"},
+		{"{name} `{email?}`", "Arthur Dent `dent@yahoo.com`\n"},
+		// non-squash empty field
+		{"{name} `{login}`", "Arthur Dent ``\n"},
+		// escapes
+		{"\\{{index}\\}", "{42}\n"},
+		{"\\\\{name}", "\\Arthur Dent\n"},
+		{"{name}\\t{email}", "Arthur Dent\tdent@yahoo.com\n"},
+		{"{name}\\q", "Arthur Dentq\n"},
+		{"{name}\\", "Arthur Dent\\\n"},
+		{"\\ü{name}", "üArthur Dent\n"},
+		// modifiers
+		{"{name:upper}", "ARTHUR DENT\n"},
+		{"{name:lower:trunc=6}", "arthur\n"},
+		{"[{name:pad=15}]", "[Arthur Dent    ]\n"},
+		{"[{name:lpad=15}]", "[    Arthur Dent]\n"},
+		{"[{name:pad=5}]", "[Arthur Dent]\n"},
+		{"[{login:pad=5}]", "[     ]\n"},
+		{"{date:\"Jan 2006\"}", "Jan 2020\n"},
+		{"{lastdate:\"Jan 2006\"}", "May 2023\n"},
+		{"{years} {name}", "2020-2023 Arthur Dent\n"},
+		{"{date:\"15:04 {Jan}\"}", "00:00 {Jan}\n"},
+		{"{name:\"Jan 2006\"}", "Arthur Dent\n"},
+		{"{email:obfuscate}", "dent at yahoo dot com\n"},
+		{"{login|email:obfuscate:upper}", "DENT AT YAHOO DOT COM\n"},
+		{"{name} `{login:pad=10?}` <{email}>", "Arthur Dent <dent@yahoo.com>\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			spec, err := compileSpec(tt.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := spec.format(testAuthor); got != tt.want {
+				t.Errorf("unexpected result:\n got: %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatSpecEscape(t *testing.T) {
+	author := defs.Author{
+		Name:    "Back`tick ``Bob``",
+		Email:   "tom&jerry@cartoon.sid",
+		Profile: "https://example.com/?a=1&b=<2>",
+	}
+
+	tests := []struct {
+		spec string
+		want string
+	}{
+		// code span
+		{"`{name}`", "``` Back`tick ``Bob`` ```\n"},
+		{"`by {name}`", "`by Back\u02CBtick \u02CB\u02CBBob\u02CB\u02CB`\n"},
+		{"`{email}`", "`tom&jerry@cartoon.sid`\n"},
+		// autolink
+		{"<{email}>", "<tom&jerry@cartoon.sid>\n"},
+		{"<{profile}>", "<https://example.com/?a=1&b=%3C2%3E>\n"},
+		// html tag
+		{`<a href="{profile}">{email}</a>`,
+			`<a href="https://example.com/?a=1&amp;b=&lt;2&gt;">tom&jerry@cartoon.sid</a>` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			spec, err := compileSpec(tt.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			spec.setEscape(escapeMarkdown)
+			if got := spec.format(author); got != tt.want {
+				t.Errorf("unexpected result:\n got: %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatSpecEscapeContext(t *testing.T) {
+	author := defs.Author{
+		Name:  "Deep *Thought*",
+		Login: "deep_th*ought=42",
+	}
+
+	tests := []struct {
+		mode string
+		spec string
+		want string
+	}{
+		// rst inline literal
+		{escapeRST, "{name} ``{login}``", "Deep \\*Thought\\* ``deep_th*ought=42``"},
+		{escapeRST, "``{name}`` {login}", "``Deep *Thought*`` deep\\_th\\*ought=42"},
+		{escapeRST, "`{login}`", "`deep\\_th\\*ought=42`"},
+		// org verbatim
+		{escapeOrg, "{name} ={login}=", "Deep *\u200BThought*\u200B =deep_th*ought=42="},
+		{escapeOrg, "={name}= {login}", "=Deep *Thought*= deep_\u200Bth*\u200Bought=\u200B42"},
+		{escapeOrg, "(={login}=)", "(=deep_th*ought=42=)"},
+		{escapeOrg, "a=b {login}", "a=b deep_\u200Bth*\u200Bought=\u200B42"},
+		// verbatim markers are not special in other markups
+		{escapeMarkdown, "={login}=", "=deep\\_th\\*ought=42="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode+"/"+tt.spec, func(t *testing.T) {
+			spec, err := compileSpec(tt.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			spec.setEscape(tt.mode)
+			if got := spec.format(author); got != tt.want+"\n" {
+				t.Errorf("unexpected result:\n got: %q\nwant: %q", got, tt.want+"\n")
+			}
+		})
+	}
+}
+
+func TestFormatSpecEscapeWidth(t *testing.T) {
+	tests := []struct {
+		mode string
+		name string
+		spec string
+		want string
+	}{
+		// escaped value is padded, so that columns are aligned
+		{escapeMarkdown, "Ford *Prefect*", "{name:pad=20}|", `Ford \*Prefect\*    |`},
+		{escapeMarkdown, "Ford *Prefect*", "{name:lpad=20}|", `    Ford \*Prefect\*|`},
+		{escapeMarkdown, "Ford *Prefect*", "{name:upper:pad=20}|", `FORD \*PREFECT\*    |`},
+		{escapeNone, "Ford *Prefect*", "{name:pad=20}|", "Ford *Prefect*      |"},
+		// escape sequence is not cut in half
+		{escapeMarkdown, "Ford *Prefect*", "{name:trunc=7}|", `Ford \*|`},
+		{escapeMarkdown, "Ford *Prefect*", "{name:trunc=6}|", "Ford |"},
+		{escapeMarkdown, "Ford *Prefect*", "{name:pad=20:trunc=18}|", `Ford \*Prefect\*  |`},
+		{escapeMarkdown, "Ford *Prefect*", "{name:lpad=20:trunc=3}|", "   |"},
+		{escapeMarkdown, "Ford *Prefect*", "{name:trunc=6:pad=8}|", "Ford    |"},
+		{escapeAsciidoc, "Ford *Prefect*", "{name:trunc=8}|", "+Ford *+|"},
+		{escapeHTML, "Tom & Jerry", "{name:trunc=8}|", "Tom |"},
+		{escapeHTML, "Tom & Jerry", "{name:trunc=9:pad=10}|", "Tom &amp; |"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode+"/"+tt.spec, func(t *testing.T) {
+			spec, err := compileSpec(tt.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			spec.setEscape(tt.mode)
+			got := spec.format(defs.Author{Name: tt.name})
+			if got != tt.want+"\n" {
+				t.Errorf("unexpected result:\n got: %q\nwant: %q", got, tt.want+"\n")
+			}
+		})
+	}
+}
+
+func TestEscapeValue(t *testing.T) {
+	tests := []struct {
+		mode  string
+		name  string
+		value string
+		want  string
+	}{
+		{escapeAsciidoc, "name", "Ford Prefect", "Ford Prefect"},
+		{escapeAsciidoc, "name", "Tom & Jerry", "Tom & Jerry"},
+		{escapeAsciidoc, "name", "*nix_guru", "+*nix_guru+"},
+		{escapeAsciidoc, "name", "{attr} [Bob]", "+{attr} [Bob]+"},
+		{escapeAsciidoc, "name", "C++ [x]", `pass:c[C++ [x\]]`},
+		{escapeAsciidoc, "profile", "https://example.com/~ix", "https://example.com/~ix"},
+		{escapeRST, "name", "Ford Prefect", "Ford Prefect"},
+		{escapeRST, "name", "*nix_guru", `\*nix\_guru`},
+		{escapeRST, "name", "Back`tick |sub| [Bob]", "Back\\`tick \\|sub\\| [Bob]"},
+		{escapeRST, "name", `C:\dos`, `C:\\dos`},
+		{escapeRST, "profile", "https://example.com/ford_prefect", "https://example.com/ford_prefect"},
+		{escapeOrg, "name", "Ford Prefect", "Ford Prefect"},
+		{escapeOrg, "name", "*nix_guru", "*\u200Bnix_\u200Bguru"},
+		{escapeOrg, "name", "[[link]] {{{macro}}}",
+			"[\u200B[\u200Blink]\u200B]\u200B {\u200B{\u200B{\u200Bmacro}\u200B}\u200B}\u200B"},
+		{escapeOrg, "email", "ford_prefect@ix.sid", "ford_prefect@ix.sid"},
+	}
+
+	for _, tt := range tests {
+		got := escapeValue(tt.mode, contextText, tt.name, tt.value)
+		if got != tt.want {
+			t.Errorf("escapeValue(%s, %q):\n got: %q\nwant: %q", tt.mode, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormatYears(t *testing.T) {
+	tests := []struct {
+		first, last string
+		want        string
+	}{
+		{"2020-01-01", "2023-05-01", "2020-2023"},
+		{"2020-01-01", "2020-12-31", "2020"},
+		{"2020-01-01", "", "2020"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := formatYears(tt.first, tt.last); got != tt.want {
+			t.Errorf("formatYears(%q, %q): got %q, want %q", tt.first, tt.last, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSpecErrors(t *testing.T) {
+	tests := []struct {
+		spec    string
+		errText string
+	}{
+		{"{", "missing trailing `}'"},
+		{"{name", "missing trailing `}'"},
+		{"{}", "empty field"},
+		{"{?}", "empty field"},
+		{"{name??}", "invalid field `name?'"},
+		{"{name|}", "invalid field `'"},
+		{"{|name}", "invalid field `'"},
+		{"{ name }", "invalid field ` name '"},
+		{"{nickname}", "unknown field `nickname'"},
+		{"{name|nickname?}", "unknown field `nickname'"},
+		{"{name:}", "invalid modifier `'"},
+		{"{name:shout}", "unknown modifier `shout'"},
+		{"{name:upper=1}", "invalid modifier `upper=1'"},
+		{"{name:pad}", "invalid modifier `pad'"},
+		{"{name:pad=-1}", "invalid modifier `pad=-1'"},
+		{"{name:pad=x}", "invalid modifier `pad=x'"},
+		{"{date:\"Jan\"x}", "invalid modifier `\"Jan\"x'"},
+		{"{date:\"Jan}", "missing trailing `}'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := compileSpec(tt.spec)
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func FuzzCompileSpec(f *testing.F) {
+	for _, spec := range []string{
+		"{index}. {name} `{login?}`\\n",
+		"- {name} `{login?}` (<{email|profile?}>)\\n",
+		"* {login|name} [{profile?}] {date}\\n",
+		"\\{{index}\\} {name}\\t<{email}>\\n",
+		"{email};{login};{name}",
+		"{", "}", "{}", "{?}", "{??}", "\\", "\\{", "{\\}", "{name?}\\",
+		"x{login?}y{profile?}z", " {login?} ",
+		"{name:upper:pad=20}", "{login|email:obfuscate?}", "{date:\"Jan 2006\"}",
+		"{date:\"}\\\"\":trunc=3}", "{name:lpad=0:lower}",
+	} {
+		f.Add(spec)
+	}
+
+	authors := []defs.Author{
+		testAuthor,
+		{},
+		{Name: " ", Login: "a b", Profile: "\n"},
+	}
+
+	f.Fuzz(func(t *testing.T, fstr string) {
+		spec, err := compileSpec(fstr)
+		if err != nil {
+			return
+		}
+
+		for _, author := range authors {
+			if out := spec.format(author); !strings.Contains(out, "\n") {
+				t.Fatalf("result has no newline: %q", out)
+			}
+		}
+
+		canon := spec.String()
+
+		spec2, err := compileSpec(canon)
+		if err != nil {
+			t.Fatalf("can't compile canonical spec %q: %s", canon, err)
+		}
+		if !reflect.DeepEqual(spec, spec2) {
+			t.Fatalf("round-trip changed spec:\n  %q\n  %q", fstr, canon)
+		}
+		if canon2 := spec2.String(); canon2 != canon {
+			t.Fatalf("canonical form is not stable:\n  %q\n  %q", canon, canon2)
+		}
+
+		if utf8.ValidString(fstr) {
+			for _, author := range authors {
+				if spec.format(author) != spec2.format(author) {
+					t.Fatalf("round-trip changed result for %q", fstr)
+				}
+			}
+		}
+	})
+}
@@ -17,13 +17,13 @@ var (
 	spaceRx      = regexp.MustCompile(`\s+`)
 )
 
-func regenerateBlock(content string, conf defs.Config) (string, error) {
+func regenerateBlock(content, dir string, conf defs.Config) (string, error) {
 	content = strings.Trim(content, "\n")
 	if content != "" {
 		content += "\n"
 	}
 
-	content, err := generateAuthors(content, conf)
+	content, err := generateAuthors(content, dir, conf)
 	if err != nil {
 		return "", err
 	}
@@ -36,52 +36,34 @@ func regenerateBlock(content string, conf defs.Config) (string, error) {
 	return content, nil
 }
 
-func generateAuthors(content string, conf defs.Config) (string, error) {
-	allAuthors, err := backend.CollectAuthors(conf)
+// Generate authors that are not yet in content.
+// Dir is directory of processed file, where .all-contributorsrc is looked up.
+func generateAuthors(content, dir string, conf defs.Config) (string, error) {
+	format, err := compileFormat(conf)
 	if err != nil {
 		return "", err
 	}
 
-	if conf.Sort == "name" {
-		sort.SliceStable(allAuthors, func(i, j int) bool {
-			return sortKey(allAuthors[i]) < sortKey(allAuthors[j])
-		})
+	newAuthors, err := loadAuthors(dir, conf)
+	if err != nil {
+		return "", err
 	}
 
 	var (
 		index int
-		added int
+		added []defs.Author
 	)
 
 	if conf.Append {
-		// Assume that 1 author = 1 non-blank line.
-		stripped := emptyLinesRx.ReplaceAllString(content, "\n")
-		stripped = strings.TrimSpace(stripped)
-		if stripped != "" {
-			stripped += "\n"
-		}
-		index = strings.Count(stripped, "\n")
+		index = format.countEntries(content, newAuthors)
 	} else {
 		content = ""
 	}
 
-	seenAuthors := make(map[string]struct{})
-
-	for _, author := range allAuthors {
-		if isIgnored(author, conf) || isBot(author) {
-			continue
-		}
-
-		author, err = backend.PopulateAuthor(author, conf)
-		if err != nil {
-			return "", err
-		}
-
-		// check again when we have more fields
-		if isIgnored(author, conf) || isBot(author) {
-			continue
-		}
+	// key => index in added, or -1
+	seenAuthors := make(map[string]int)
 
+	for _, author := range newAuthors {
 		var uniqKeys, extraKeys, allKeys []string
 
 		if author.Email != "" {
@@ -106,10 +88,10 @@ func generateAuthors(content string, conf defs.Config) (string, error) {
 		allKeys = append(allKeys, uniqKeys...)
 		allKeys = append(allKeys, extraKeys...)
 
-		found := false
+		found, idx := false, -1
 		for _, key := range allKeys {
-			_, found = seenAuthors[key]
-			if found {
+			if n, ok := seenAuthors[key]; ok {
+				found, idx = true, n
 				break
 			}
 		}
@@ -139,44 +121,110 @@ func generateAuthors(content string, conf defs.Config) (string, error) {
 		}
 
 		if !found {
-			added += 1
 			index += 1
 			author.Index = index
 
-			line, err := formatAuthor(author, conf)
-			if err != nil {
-				return "", err
-			}
-
-			if conf.Pipe {
-				// In --pipe mode, print immediately.
-				fmt.Print(line)
-			} else {
+			if !conf.Pipe {
 				logs.Infof("new: %s <%s> %s",
 					author.Name, author.Email, author.Login)
-				content += line
 			}
+
+			added = append(added, author)
+			idx = len(added) - 1
 		} else {
 			logs.Debugf("dup: %s <%s> %s",
 				author.Name, author.Email, author.Login)
+
+			// same person with another email or name
+			if idx >= 0 && author.LastDate > added[idx].LastDate {
+				added[idx].LastDate = author.LastDate
+			}
 		}
 
 		for _, key := range uniqKeys {
-			seenAuthors[key] = struct{}{}
+			if _, ok := seenAuthors[key]; !ok {
+				seenAuthors[key] = idx
+			}
 		}
 	}
 
-	if !conf.Pipe {
-		if added == 0 {
+	pos, text, err := format.formatAuthors(content, added)
+	if err != nil {
+		return "", err
+	}
+
+	if conf.Pipe {
+		// In --pipe mode, print to stdout instead.
+		fmt.Print(text)
+	} else {
+		content = content[:pos] + text + content[pos:]
+
+		if len(added) == 0 {
 			logs.Infof("no new authors")
 		} else {
-			logs.Infof("added %d author(s)", added)
+			logs.Infof("added %d author(s)", len(added))
 		}
 	}
 
 	return content, nil
 }
 
+// Collect, sort, filter, and populate authors.
+// Result may contain duplicates.
+func loadAuthors(dir string, conf defs.Config) ([]defs.Author, error) {
+	allAuthors, err := backend.CollectAuthors(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.Sort == "name" {
+		sort.SliceStable(allAuthors, func(i, j int) bool {
+			return sortKey(allAuthors[i]) < sortKey(allAuthors[j])
+		})
+	}
+
+	authors := filterAuthors(allAuthors, conf)
+
+	authors, err = backend.PopulateAuthors(authors, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// check again when we have more fields, e.g. login
+	authors = filterAuthors(authors, conf)
+
+	if err := importRoles(dir, authors); err != nil {
+		return nil, err
+	}
+
+	return authors, nil
+}
+
+// Remove ignored authors and bots.
+func filterAuthors(authors []defs.Author, conf defs.Config) []defs.Author {
+	var result []defs.Author
+
+	for _, author := range authors {
+		if isIgnored(author, conf) || isBot(author) {
+			continue
+		}
+		result = append(result, author)
+	}
+
+	return result
+}
+
+// Count non-blank lines.
+func countLines(content string) int {
+	stripped := emptyLinesRx.ReplaceAllString(content, "\n")
+	stripped = strings.TrimSpace(stripped)
+	if stripped != "" {
+		stripped += "\n"
+	}
+
+	return strings.Count(stripped, "\n")
+}
+
 func sortKey(a defs.Author) string {
 	switch {
 	case a.Name != "":
@@ -0,0 +1,228 @@
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gavv/md-authors/src/defs"
+	"github.com/gavv/md-authors/src/logs"
+)
+
+// Package manifests with author lists.
+const (
+	packageJSONFile = "package.json"
+	cargoFile       = "Cargo.toml"
+	pyprojectFile   = "pyproject.toml"
+)
+
+// "Name <email> (url)", each part is optional.
+var personRx = regexp.MustCompile(`^\s*([^<(]*?)\s*(?:<([^>]*)>)?\s*(?:\(([^)]*)\))?\s*$`)
+
+// Entry of author list in package manifest.
+// Raw is formatted entry, other fields are used to match
+// entry with authors.
+type manifestEntry struct {
+	raw   string
+	name  string
+	email string
+	url   string
+}
+
+// Build new author list.
+//
+// Like with markdown blocks, if appendMode is true, existing entries
+// are kept and new authors are added to the end. Otherwise, the list
+// is regenerated from scratch.
+//
+// Returns new list and whether it differs from old one.
+func mergeManifest(
+	entries []manifestEntry,
+	authors []defs.Author,
+	format func(defs.Author) manifestEntry,
+	appendMode bool,
+) ([]manifestEntry, bool) {
+	var result []manifestEntry
+
+	if appendMode {
+		result = append(result, entries...)
+	}
+
+	added := 0
+
+	for _, author := range uniqueAuthors(authors) {
+		if author.Name == "" && author.Login == "" {
+			logs.Debugf("skip: <%s>: no name", author.Email)
+			continue
+		}
+
+		found := false
+		for _, entry := range entries {
+			if matchManifestEntry(entry, author) {
+				found = true
+				break
+			}
+		}
+
+		if found && appendMode {
+			logs.Debugf("dup: %s <%s> %s", author.Name, author.Email, author.Login)
+			continue
+		}
+		if !found {
+			logs.Infof("new: %s <%s> %s", author.Name, author.Email, author.Login)
+			added++
+		}
+
+		result = append(result, format(author))
+	}
+
+	if added != 0 {
+		logs.Infof("added %d author(s)", added)
+	}
+
+	changed := len(result) != len(entries)
+	for n := 0; !changed && n < len(result); n++ {
+		changed = result[n].raw != entries[n].raw
+	}
+
+	return result, changed
+}
+
+// Check if entry refers to author.
+func matchManifestEntry(entry manifestEntry, author defs.Author) bool {
+	switch {
+	case entry.email != "" && strings.EqualFold(entry.email, author.Email):
+		return true
+	case entry.url != "" && strings.EqualFold(entry.url, author.Profile):
+		return true
+	case entry.name != "" && strings.EqualFold(joinName(entry.name), joinName(author.Name)):
+		return true
+	case entry.name != "" && strings.EqualFold(entry.name, author.Login):
+		return true
+	}
+
+	return false
+}
+
+// Display name of author in manifest.
+func manifestName(author defs.Author) string {
+	if author.Name != "" {
+		return author.Name
+	}
+
+	return author.Login
+}
+
+// Parse "Name <email> (url)" string.
+func parsePerson(s string) manifestEntry {
+	entry := manifestEntry{name: strings.TrimSpace(s)}
+
+	if m := personRx.FindStringSubmatch(s); m != nil {
+		entry.name, entry.email, entry.url = m[1], m[2], m[3]
+	}
+
+	return entry
+}
+
+// Format "Name <email> (url)" string, empty parts are omitted.
+func formatPerson(name, email, url string) string {
+	parts := []string{name}
+
+	if email != "" {
+		parts = append(parts, "<"+email+">")
+	}
+	if url != "" {
+		parts = append(parts, "("+url+")")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Update "contributors" array in package.json.
+// Entries are written in npm's short "Name <email> (url)" form,
+// existing entries may also be objects.
+func processPackageJSON(data []byte, authors []defs.Author, conf defs.Config) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("file is empty or doesn't exist")
+	}
+
+	keys, values, err := parseJSONObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+
+	var oldValues []json.RawMessage
+
+	idx := -1
+	for n, key := range keys {
+		if key == "contributors" {
+			idx = n
+			if err := json.Unmarshal(values[n], &oldValues); err != nil {
+				return nil, fmt.Errorf("invalid contributors: %w", err)
+			}
+		}
+	}
+	if idx < 0 {
+		keys = append(keys, "contributors")
+		values = append(values, nil)
+		idx = len(keys) - 1
+	}
+
+	var oldEntries []manifestEntry
+
+	for _, raw := range oldValues {
+		entry, err := parsePackageJSONEntry(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid contributors: %w", err)
+		}
+		oldEntries = append(oldEntries, entry)
+	}
+
+	newEntries, changed := mergeManifest(oldEntries, authors, func(author defs.Author) manifestEntry {
+		return manifestEntry{
+			raw: string(marshalJSON(
+				formatPerson(manifestName(author), author.Email, author.Profile))),
+		}
+	}, conf.Append)
+
+	if !changed {
+		// nothing changed, keep formatting
+		return data, nil
+	}
+
+	newValues := []json.RawMessage{}
+	for _, entry := range newEntries {
+		newValues = append(newValues, json.RawMessage(entry.raw))
+	}
+	values[idx] = marshalJSON(newValues)
+
+	return updateJSONObject(data, keys, values)
+}
+
+// Parse entry of "contributors", either string or object.
+func parsePackageJSONEntry(raw json.RawMessage) (manifestEntry, error) {
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		entry := parsePerson(str)
+		entry.raw = string(raw)
+		return entry, nil
+	}
+
+	var obj struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		URL   string `json:"url"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return manifestEntry{}, err
+	}
+
+	return manifestEntry{
+		raw:   string(raw),
+		name:  obj.Name,
+		email: obj.Email,
+		url:   obj.URL,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package gen
+
+import (
+	"testing"
+
+	"github.com/gavv/md-authors/src/defs"
+)
+
+func TestProcessPackageJSONErrors(t *testing.T) {
+	for _, data := range []string{
+		``,
+		`[]`,
+		`{"contributors": {}}`,
+		`{"contributors": [1]}`,
+	} {
+		if _, err := processPackageJSON([]byte(data), testStructuredAuthors,
+			defs.Config{}); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestParsePerson(t *testing.T) {
+	tests := []struct {
+		input string
+		want  manifestEntry
+	}{
+		{"Arthur Dent", manifestEntry{name: "Arthur Dent"}},
+		{"Arthur Dent <arthur@example.com>",
+			manifestEntry{name: "Arthur Dent", email: "arthur@example.com"}},
+		{"Arthur Dent (https://example.com)",
+			manifestEntry{name: "Arthur Dent", url: "https://example.com"}},
+		{" Arthur Dent  <arthur@example.com> (https://example.com) ",
+			manifestEntry{name: "Arthur Dent", email: "arthur@example.com",
+				url: "https://example.com"}},
+		{"<arthur@example.com>", manifestEntry{email: "arthur@example.com"}},
+	}
+
+	for _, tt := range tests {
+		if got := parsePerson(tt.input); got != tt.want {
+			t.Errorf("parsePerson(%q): got %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, tt := range tests[:4] {
+		got := formatPerson(tt.want.name, tt.want.email, tt.want.url)
+		if parsePerson(got) != tt.want {
+			t.Errorf("formatPerson(%+v): got %q", tt.want, got)
+		}
+	}
+}
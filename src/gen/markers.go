@@ -0,0 +1,271 @@
+package gen
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gavv/md-authors/src/defs"
+)
+
+// Syntax of magic comments surrounding author block in markup.
+type blockMarkers struct {
+	// Marker pair, used in error messages, with %[1]s for
+	// marker name.
+	display string
+	// Regexps of text before and after marker name.
+	prefix string
+	suffix string
+	// Separate block contents from markers with blank lines.
+	padding bool
+	// Builtin format used when neither --format nor --template
+	// is specified.
+	format string
+	// If not empty, used instead of --escape=auto.
+	escape string
+}
+
+// Optional block attributes, like: key=value key="value"
+const blockAttrsRx = `((?:\s+[\w-]+=(?:"[^"]*"|[^\s">]*))*)`
+
+// Marker name used when --marker is not specified.
+const defaultMarkerName = "authors"
+
+// Block markers by markup name (--markers option).
+var markupMarkers = map[string]blockMarkers{
+	// <!-- authors --> / <!-- endauthors -->
+	"markdown": {
+		display: "<!--%[1]s-->/<!--end%[1]s-->",
+		prefix:  `\s*<!--\s*`,
+		suffix:  `\s*-->\s*`,
+		padding: true,
+		format:  BuiltinFormats["modern"],
+	},
+
+	// same, but without blank lines and markdown escaping
+	"html": {
+		display: "<!--%[1]s-->/<!--end%[1]s-->",
+		prefix:  `\s*<!--\s*`,
+		suffix:  `\s*-->\s*`,
+		format:  BuiltinFormats["html"],
+		escape:  escapeHTML,
+	},
+
+	// .. authors / .. endauthors
+	// Blank lines are required, otherwise following lines
+	// would become part of the comment.
+	"rst": {
+		display: ".. %[1]s/.. end%[1]s",
+		prefix:  `\s*\.\.\s+`,
+		suffix:  `\s*`,
+		padding: true,
+		format:  BuiltinFormats["rst"],
+		escape:  escapeRST,
+	},
+
+	// // authors / // endauthors
+	"asciidoc": {
+		display: "// %[1]s/// end%[1]s",
+		prefix:  `\s*//\s*`,
+		suffix:  `\s*`,
+		padding: true,
+		format:  BuiltinFormats["asciidoc"],
+		escape:  escapeAsciidoc,
+	},
+
+	// # authors / # endauthors
+	"org": {
+		display: "# %[1]s/# end%[1]s",
+		prefix:  `\s*#\s+`,
+		suffix:  `\s*`,
+		padding: true,
+		format:  BuiltinFormats["org"],
+		escape:  escapeOrg,
+	},
+
+	// # authors / # endauthors
+	// Comment lines must start at first column, and blank lines
+	// are not allowed inside deb822 paragraphs.
+	"debian": {
+		display: "# %[1]s/# end%[1]s",
+		prefix:  `#\s*`,
+		suffix:  `\s*`,
+		format:  BuiltinFormats["dep5"],
+		escape:  escapeNone,
+	},
+}
+
+// Markup names by file extension.
+// Files with other extensions are treated as markdown.
+var markupExtensions = map[string]string{
+	".md":       "markdown",
+	".markdown": "markdown",
+	".html":     "html",
+	".htm":      "html",
+	".rst":      "rst",
+	".adoc":     "asciidoc",
+	".asciidoc": "asciidoc",
+	".org":      "org",
+}
+
+// Name of block markers, from --marker option.
+type markerName struct {
+	// Matches whole name.
+	rx *regexp.Regexp
+	// Default block attributes.
+	attrs string
+}
+
+// Block markers of markup, with given names.
+type blockMatcher struct {
+	markers blockMarkers
+	names   []markerName
+	begin   *regexp.Regexp
+	end     *regexp.Regexp
+}
+
+func newBlockMatcher(markers blockMarkers, names []markerName) *blockMatcher {
+	return &blockMatcher{
+		markers: markers,
+		names:   names,
+		begin: regexp.MustCompile(
+			`^` + markers.prefix + `([\w-]+)` + blockAttrsRx + markers.suffix + `$`),
+		end: regexp.MustCompile(
+			`^` + markers.prefix + `end([\w-]+)` + markers.suffix + `$`),
+	}
+}
+
+// Check if line is begin marker.
+// Returns marker name and block attributes, including default ones.
+func (bm *blockMatcher) matchBegin(line string) (string, string, bool) {
+	m := bm.begin.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false
+	}
+
+	// name regexp like ".*authors" matches "endauthors" too
+	if _, ok := bm.matchEnd(line); ok {
+		return "", "", false
+	}
+
+	for _, name := range bm.names {
+		if name.rx.MatchString(m[1]) {
+			return m[1], name.attrs + m[2], true
+		}
+	}
+
+	return "", "", false
+}
+
+// Check if line is end marker.
+// Returns marker name.
+func (bm *blockMatcher) matchEnd(line string) (string, bool) {
+	m := bm.end.FindStringSubmatch(line)
+	if m == nil {
+		return "", false
+	}
+
+	for _, name := range bm.names {
+		if name.rx.MatchString(m[1]) {
+			return m[1], true
+		}
+	}
+
+	return "", false
+}
+
+// Format marker pair for error messages.
+func (bm *blockMatcher) display(name string) string {
+	return fmt.Sprintf(bm.markers.display, name)
+}
+
+// Parse --marker values.
+// Each value is a regexp of marker name, optionally followed by
+// default block attributes, like "contrib(utor)?s format=classic".
+func parseMarkerNames(values []string) ([]markerName, error) {
+	if len(values) == 0 {
+		values = []string{defaultMarkerName}
+	}
+
+	attrsRx := regexp.MustCompile(`^` + blockAttrsRx + `$`)
+
+	var names []markerName
+
+	for _, value := range values {
+		pattern, attrs, _ := strings.Cut(strings.TrimSpace(value), " ")
+
+		rx, err := regexp.Compile(`^(?:` + pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("bad marker %q: %w", value, err)
+		}
+
+		if attrs != "" {
+			attrs = " " + strings.TrimSpace(attrs)
+			if !attrsRx.MatchString(attrs) {
+				return nil, fmt.Errorf("bad marker %q: can't parse attributes", value)
+			}
+		}
+
+		names = append(names, markerName{rx: rx, attrs: attrs})
+	}
+
+	return names, nil
+}
+
+// List of supported markup names, sorted.
+func MarkupNames() []string {
+	var names []string
+	for name := range markupMarkers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Select block markers by --markers option, or by file name
+// if it's "auto".
+func findMarkers(path string, conf defs.Config) (blockMarkers, error) {
+	markup := conf.Markers
+
+	if markup == "" || markup == "auto" {
+		markup = "markdown"
+		if isDebianCopyright(path) {
+			markup = "debian"
+		} else if name, ok := markupExtensions[strings.ToLower(filepath.Ext(path))]; ok {
+			markup = name
+		}
+	}
+
+	markers, ok := markupMarkers[markup]
+	if !ok {
+		return blockMarkers{}, fmt.Errorf("unknown markup %q", markup)
+	}
+
+	return markers, nil
+}
+
+// Apply markup-specific defaults to config.
+func applyMarkers(conf defs.Config, markers blockMarkers) defs.Config {
+	if conf.Format == "" && conf.Template == "" {
+		conf.Format = markers.format
+	}
+	if markers.escape != "" && (conf.Escape == "" || conf.Escape == escapeAuto) {
+		conf.Escape = markers.escape
+	}
+
+	return conf
+}
+
+// Check if file is debian/copyright, in machine-readable
+// format (DEP-5).
+func isDebianCopyright(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasSuffix(filepath.ToSlash(abs), "/debian/copyright")
+}
@@ -1,81 +1,119 @@
 package gen
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/gavv/md-authors/src/defs"
 	"github.com/gavv/md-authors/src/logs"
 )
 
-// Update author blocks in markdown file.
+var blockAttrRx = regexp.MustCompile(`([\w-]+)=("[^"]*"|[^\s">]*)`)
+
+// Update author blocks in markdown file, or other markup
+// file with corresponding block markers.
 // If --append is set, only appends new authors to the block and
 // doesn't touch original block contents.
 func ProcessFile(path string, conf defs.Config) error {
-	logs.Debugf("processing %q", path)
+	if sf := findStructuredFile(path); sf != nil {
+		return processStructuredFile(path, sf, conf)
+	}
+
+	markers, err := findMarkers(path, conf)
+	if err != nil {
+		return fmt.Errorf("can't process %q: %w", path, err)
+	}
 
-	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	names, err := parseMarkerNames(conf.MarkerNames)
 	if err != nil {
-		return fmt.Errorf("can't open %q: %w", path, err)
+		return fmt.Errorf("can't process %q: %w", path, err)
 	}
-	defer file.Close()
 
-	var oldContent, newContent bytes.Buffer
+	matcher := newBlockMatcher(markers, names)
 
-	scanner := bufio.NewScanner(file)
+	logs.Debugf("processing %q", path)
+
+	// read whole file, so that line length is not limited
+	oldContent, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("can't read %q: %w", path, err)
+	}
 
 	var (
+		newContent   bytes.Buffer
 		blockBuilder strings.Builder
 		blockFlag    bool
+		blockName    string
+		blockConf    defs.Config
 		lineNo       int
 	)
 
-	for scanner.Scan() {
-		line := scanner.Text()
+	style := detectTextStyle(oldContent)
+
+	// Lines outside of blocks, including markers, are written back
+	// as is, with original line endings. Generated content uses
+	// the same style as the first line.
+	for _, rawLine := range strings.SplitAfter(string(oldContent), "\n") {
+		if rawLine == "" {
+			// after last newline
+			continue
+		}
+
+		line, _ := splitLineEnding(rawLine)
 		lineNo++
 
-		oldContent.WriteString(line)
-		oldContent.WriteString("\n")
+		if lineNo == 1 {
+			line = style.decode(line)
+		}
 
 		// begin block
-		if matched, _ := regexp.MatchString(
-			`^\s*<!--\s*authors\s*-->\s*$`, line); matched {
+		if name, attrs, ok := matcher.matchBegin(line); ok {
 			if blockFlag {
 				return fmt.Errorf(
-					"can't process %q: unpaired <!--authors-->/<!--endauthors--> at line %d",
-					path, lineNo)
+					"can't process %q: unpaired %s at line %d",
+					path, matcher.display(blockName), lineNo)
 			}
 
-			newContent.WriteString(line)
-			newContent.WriteString("\n")
+			blockConf, err = applyBlockAttrs(conf, attrs)
+			if err != nil {
+				return fmt.Errorf("can't process %q: %w at line %d", path, err, lineNo)
+			}
+			blockConf = applyMarkers(blockConf, markers)
+
+			newContent.WriteString(rawLine)
 
 			blockFlag = true
+			blockName = name
 			continue
 		}
 
 		// end block
-		if matched, _ := regexp.MatchString(
-			`^\s*<!--\s*endauthors\s*-->\s*$`, line); matched {
-			if !blockFlag {
+		if name, ok := matcher.matchEnd(line); ok {
+			if !blockFlag || name != blockName {
 				return fmt.Errorf(
-					"can't process %q: unpaired <!--authors-->/<!--endauthors--> at line %d",
-					path, lineNo)
+					"can't process %q: unpaired %s at line %d",
+					path, matcher.display(name), lineNo)
 			}
 
 			content := blockBuilder.String()
-			content, err = regenerateBlock(content, conf)
+			if markers.padding {
+				content, err = regenerateBlock(content, filepath.Dir(path), blockConf)
+			} else {
+				content, err = generateAuthors(content, filepath.Dir(path), blockConf)
+			}
 			if err != nil {
 				return fmt.Errorf("can't open %q: %w", path, err)
 			}
 
-			newContent.WriteString(content)
-			newContent.WriteString(line)
-			newContent.WriteString("\n")
+			newContent.WriteString(style.newlines(content))
+			newContent.WriteString(rawLine)
 
 			blockFlag = false
 			blockBuilder.Reset()
@@ -90,38 +128,65 @@ func ProcessFile(path string, conf defs.Config) error {
 		}
 
 		// outside of block
-		newContent.WriteString(line)
-		newContent.WriteString("\n")
-	}
-
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("can't read %q: %w", path, err)
+		newContent.WriteString(rawLine)
 	}
 
 	if blockFlag {
 		return fmt.Errorf(
-			"can't process %q: unpaired <!--authors-->/<!--endauthors--> at line %d",
-			path, lineNo)
+			"can't process %q: unpaired %s at line %d",
+			path, matcher.display(blockName), lineNo)
 	}
 
-	if !bytes.Equal(newContent.Bytes(), oldContent.Bytes()) {
-		_, err = file.Seek(0, 0)
-		if err != nil {
+	if !bytes.Equal(newContent.Bytes(), oldContent) {
+		if err := writeFileAtomic(path, newContent.Bytes()); err != nil {
 			return fmt.Errorf("can't write %q: %w", path, err)
 		}
+	}
 
-		err = file.Truncate(0)
-		if err != nil {
-			return fmt.Errorf("can't write %q: %w", path, err)
-		}
+	return nil
+}
 
-		_, err = file.Write(newContent.Bytes())
-		if err != nil {
-			return fmt.Errorf("can't write %q: %w", path, err)
+// Override config with block attributes, like:
+//
+//	<!-- authors escape=none format=classic -->
+func applyBlockAttrs(conf defs.Config, attrs string) (defs.Config, error) {
+	for _, m := range blockAttrRx.FindAllStringSubmatch(attrs, -1) {
+		key, value := m[1], strings.Trim(m[2], `"`)
+
+		switch key {
+		case "escape":
+			if _, err := resolveEscape(value, conf.Pipe); err != nil {
+				return conf, err
+			}
+			conf.Escape = value
+		case "format":
+			format, err := ResolveFormat(value)
+			if err != nil {
+				return conf, err
+			}
+			conf.Format = format
+			conf.Template = ""
+		case "sort":
+			switch value {
+			case "date", "name":
+			default:
+				return conf, fmt.Errorf("unknown sort order %q", value)
+			}
+			conf.Sort = value
+		case "append":
+			flag, err := strconv.ParseBool(value)
+			if err != nil {
+				return conf, fmt.Errorf("bad value of append %q", value)
+			}
+			conf.Append = flag
+		case "ignore":
+			conf.Ignore = append(slices.Clip(conf.Ignore), strings.Split(value, ",")...)
+		default:
+			return conf, fmt.Errorf("unknown block attribute %q", key)
 		}
 	}
 
-	return nil
+	return conf, nil
 }
 
 // Print author block to stdout.
@@ -138,6 +203,12 @@ func ProcessPipe(conf defs.Config) error {
 		content = string(b)
 	}
 
-	_, err := generateAuthors(content, conf)
+	markers, err := findMarkers("", conf)
+	if err != nil {
+		return err
+	}
+	conf = applyMarkers(conf, markers)
+
+	_, err = generateAuthors(content, ".", conf)
 	return err
 }
@@ -0,0 +1,884 @@
+package gen
+
+import (
+	"flag"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gavv/md-authors/src/cache"
+	"github.com/gavv/md-authors/src/defs"
+)
+
+// When set, golden files are overwritten with actual output.
+var updateFlag = flag.Bool("update", false, "update golden files")
+
+func TestMain(m *testing.M) {
+	cache.SetStore(cache.NewMemoryStore())
+
+	os.Exit(m.Run())
+}
+
+// Scripted history of test repo.
+// Each line is "date;name;email".
+var testCommits = []string{
+	"2020-01-01;Arthur Dent;dent@yahoo.com",
+	"2020-02-01;Ford Prefect;ford@betelgeuse7.sid",
+	"2020-02-15;Ford Prefect;ford@betelgeuse7.sid",
+	"2020-03-01;dependabot[bot];49699333+dependabot[bot]@users.noreply.github.com",
+	"2021-01-01;Tricia McMillan;trillian@heartofgold.sid",
+	"2021-06-01;Marvin;marvin@sirius.cyb",
+	"2022-01-01;Zaphod Beeblebrox;zaphod@heartofgold.sid",
+	"2023-05-01;Arthur Dent;dent@yahoo.com",
+}
+
+// Authors with special characters in names.
+var testEscapeCommits = []string{
+	"2020-01-01;*nix_guru;guru@unix.sid",
+	"2020-02-01;Tom <Jerry> & Co;tom&jerry@cartoon.sid",
+	"2020-03-01;Back`tick [Bob];bob@ticks.sid",
+	"2020-04-01;Curly {attr} C++;curly@braces.sid",
+}
+
+// Commits with GitHub noreply email, so that login with
+// special characters is known without api requests.
+var testEscapeLoginCommits = []string{
+	"2020-01-01;Ford *Prefect*;ford@betelgeuse7.sid",
+	"2020-02-01;Deep Thought;4242+deep_th*ought=42@users.noreply.github.com",
+}
+
+// Create throwaway git repo with testCommits and chdir into it.
+func setupRepo(t *testing.T) string {
+	t.Helper()
+
+	return setupRepoCommits(t, testCommits)
+}
+
+// Create throwaway git repo with given commits and chdir into it.
+func setupRepoCommits(t *testing.T, commits []string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	git := func(env []string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), env...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+
+	git(nil, "init", "-q")
+
+	for n, commit := range commits {
+		split := strings.Split(commit, ";")
+		date := split[0] + "T12:00:00Z"
+		env := []string{
+			"GIT_AUTHOR_DATE=" + date,
+			"GIT_AUTHOR_NAME=" + split[1],
+			"GIT_AUTHOR_EMAIL=" + split[2],
+			"GIT_COMMITTER_DATE=" + date,
+			"GIT_COMMITTER_NAME=" + split[1],
+			"GIT_COMMITTER_EMAIL=" + split[2],
+		}
+		git(env, "commit", "-q", "--allow-empty", "--no-gpg-sign",
+			"-m", "commit "+string(rune('A'+n)))
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(oldDir)
+	})
+
+	return dir
+}
+
+func testConfig() defs.Config {
+	return defs.Config{
+		Format:    "{index}. {name} `{login?}`\\n",
+		Sort:      "date",
+		NoProject: true,
+		Jobs:      1,
+	}
+}
+
+// Compare output with golden file, or update golden file if -update is set.
+func checkGolden(t *testing.T, golden string, actual string) {
+	t.Helper()
+
+	if *updateFlag {
+		if err := os.WriteFile(golden, []byte(actual), 0644); err != nil {
+			t.Fatalf("can't update golden file: %s", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("can't read golden file: %s", err)
+	}
+
+	if actual != string(expected) {
+		t.Errorf("output doesn't match %s\n--- got:\n%s\n--- want:\n%s",
+			golden, actual, expected)
+	}
+}
+
+// Template with authors grouped by year of first contribution.
+const groupsTemplate = `{{define "groupby"}}{{.Date | date "2006"}}{{end}}` +
+	`{{define "group"}}` + "\n**{{.Key}}**\n\n" + `{{end}}` +
+	`- {{.Name}}, {{.Index}}`
+
+func TestProcessFile(t *testing.T) {
+	tests := []struct {
+		name string
+		conf func(*defs.Config)
+	}{
+		{
+			name: "replace_empty",
+		},
+		{
+			name: "replace_existing",
+		},
+		{
+			name: "append",
+			conf: func(c *defs.Config) {
+				c.Append = true
+			},
+		},
+		{
+			name: "append_uptodate",
+			conf: func(c *defs.Config) {
+				c.Append = true
+			},
+		},
+		{
+			name: "multiple_blocks",
+		},
+		{
+			name: "no_blocks",
+		},
+		{
+			// regexp also matches end markers
+			name: "regexp_marker",
+			conf: func(c *defs.Config) {
+				c.MarkerNames = []string{".*authors"}
+			},
+		},
+		{
+			name: "named_blocks",
+			conf: func(c *defs.Config) {
+				c.MarkerNames = []string{
+					"authors",
+					"contrib(utor)?s sort=name format=\"- {name}\\n\"",
+				}
+			},
+		},
+		{
+			name: "block_attrs",
+		},
+		{
+			name: "sort_name",
+			conf: func(c *defs.Config) {
+				c.Sort = "name"
+			},
+		},
+		{
+			name: "ignore",
+			conf: func(c *defs.Config) {
+				c.Ignore = []string{"marvin@sirius.cyb", "Arthur Dent"}
+			},
+		},
+		{
+			name: "squash_classic",
+			conf: func(c *defs.Config) {
+				c.Format = "- {name} `{login?}` (<{email|profile?}>)\\n"
+			},
+		},
+		{
+			name: "squash_fallback",
+			conf: func(c *defs.Config) {
+				c.Format = "* {login|name} [{profile?}] {date}\\n"
+			},
+		},
+		{
+			name: "escapes",
+			conf: func(c *defs.Config) {
+				c.Format = "\\{{index}\\} {name}\\t<{email}>\\n"
+			},
+		},
+		{
+			name: "builtin_table",
+			conf: func(c *defs.Config) {
+				c.Format = BuiltinFormats["table"]
+			},
+		},
+		{
+			name: "builtin_table_append",
+			conf: func(c *defs.Config) {
+				c.Append = true
+				c.Format = BuiltinFormats["table"]
+			},
+		},
+		{
+			name: "builtin_avatars",
+			conf: func(c *defs.Config) {
+				c.Format = BuiltinFormats["avatars"]
+				c.AvatarSize = 32
+				c.AvatarColumns = 2
+			},
+		},
+		{
+			name: "builtin_avatars_append",
+			conf: func(c *defs.Config) {
+				c.Append = true
+				c.Format = BuiltinFormats["avatars"]
+				c.AvatarSize = 32
+				c.AvatarColumns = 2
+			},
+		},
+		{
+			name: "template",
+			conf: func(c *defs.Config) {
+				c.Format = "tmpl:{{.Index}}. {{.Name | upper | mdescape}}" +
+					" ({{.Date | date \"Jan 2006\"}})"
+			},
+		},
+		{
+			name: "template_block",
+			conf: func(c *defs.Config) {
+				c.Template = `{{define "block"}}` +
+					`{{range .}}- {{.Name | lower}}{{if .Login}} @{{.Login}}{{end}}` + "\n" +
+					`{{end}}({{len .}} total){{end}}`
+			},
+		},
+		{
+			name: "template_append",
+			conf: func(c *defs.Config) {
+				c.Append = true
+				c.Template = "{{.Index}}. {{truncate 5 .Name}}\n"
+			},
+		},
+		{
+			name: "template_table",
+			conf: func(c *defs.Config) {
+				c.Template = `{{define "header"}}| # | Name | Since |` + "\n" +
+					`|---|------|-------|` + "\n" + `{{end}}` +
+					`| {{.Index}} | {{.Name}} | {{.Date}} |`
+			},
+		},
+		{
+			name: "template_html_append",
+			conf: func(c *defs.Config) {
+				c.Append = true
+				c.Template = `{{define "header"}}<ul>` + "\n" + `{{end}}` +
+					`{{define "footer"}}</ul>` + "\n" + `{{end}}` +
+					`  <li>{{.Name}}</li>`
+			},
+		},
+		{
+			name: "template_groups",
+			conf: func(c *defs.Config) {
+				c.Template = groupsTemplate
+			},
+		},
+		{
+			name: "template_groups_append",
+			conf: func(c *defs.Config) {
+				c.Append = true
+				c.Template = groupsTemplate
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := filepath.Join("testdata", tt.name+".md")
+			golden := filepath.Join("testdata", tt.name+".golden.md")
+
+			content, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			input, _ = filepath.Abs(input)
+			golden, _ = filepath.Abs(golden)
+
+			dir := setupRepo(t)
+			path := filepath.Join(dir, "AUTHORS.md")
+
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			conf := testConfig()
+			if tt.conf != nil {
+				tt.conf(&conf)
+			}
+
+			if err := ProcessFile(path, conf); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			actual, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkGolden(t, golden, string(actual))
+		})
+	}
+}
+
+func TestProcessDebianCopyright(t *testing.T) {
+	tests := []struct {
+		name       string
+		appendMode bool
+	}{
+		{"debian_copyright", false},
+		{"debian_copyright_append", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("testdata", tt.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden, _ := filepath.Abs(filepath.Join("testdata", tt.name+".golden"))
+
+			dir := setupRepo(t)
+			path := filepath.Join(dir, "debian", "copyright")
+
+			if err := os.Mkdir(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// use default format for debian/copyright
+			conf := testConfig()
+			conf.Format = ""
+			conf.Append = tt.appendMode
+			conf.Escape = escapeAuto
+
+			if err := ProcessFile(path, conf); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			actual, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkGolden(t, golden, string(actual))
+		})
+	}
+}
+
+func TestProcessMarkup(t *testing.T) {
+	// github api stub that knows nothing
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("content-type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+		}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		file    string
+		markers string
+		// if true, logins are populated
+		logins bool
+	}{
+		{name: "markup", file: "markup.html"},
+		{name: "markup", file: "markup.rst"},
+		{name: "markup", file: "markup.adoc"},
+		{name: "markup", file: "markup.org"},
+		// explicit --markers
+		{name: "markup_rst", file: "markup_rst.txt", markers: "rst"},
+		// logins in verbatim text
+		{name: "markup_login", file: "markup.rst", logins: true},
+		{name: "markup_login", file: "markup.org", logins: true},
+	}
+
+	for _, tt := range tests {
+		ext := filepath.Ext(tt.file)
+
+		t.Run(tt.name+ext, func(t *testing.T) {
+
+			content, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden, _ := filepath.Abs(filepath.Join("testdata", tt.name+".golden"+ext))
+
+			commits := testEscapeCommits
+			if tt.logins {
+				commits = testEscapeLoginCommits
+			}
+
+			dir := setupRepoCommits(t, commits)
+			path := filepath.Join(dir, "AUTHORS"+ext)
+
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// use default format and escaping for markup
+			conf := testConfig()
+			conf.Format = ""
+			conf.Escape = escapeAuto
+			conf.Markers = tt.markers
+
+			if tt.logins {
+				conf.NoProject = false
+				conf.Project = "magrathea/earth"
+				conf.GithubAPI = server.URL
+				conf.Token = "test"
+			}
+
+			if err := ProcessFile(path, conf); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			actual, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkGolden(t, golden, string(actual))
+		})
+	}
+}
+
+func TestProcessFileLineEndings(t *testing.T) {
+	const (
+		input = "# Authors\n\n<!-- authors -->\n- Old\n<!-- endauthors -->\n\nFooter\n"
+		want  = "# Authors\n\n<!-- authors -->\n\n1. Arthur Dent\n2. Ford Prefect\n" +
+			"3. Tricia McMillan\n4. Marvin\n5. Zaphod Beeblebrox\n\n" +
+			"<!-- endauthors -->\n\nFooter\n"
+	)
+
+	crlf := func(s string) string {
+		return strings.ReplaceAll(s, "\n", "\r\n")
+	}
+	noFinal := func(s string) string {
+		return strings.TrimSuffix(s, "\n")
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{
+			name:    "lf",
+			content: input,
+			want:    want,
+		},
+		{
+			name:    "crlf",
+			content: crlf(input),
+			want:    crlf(want),
+		},
+		{
+			name:    "bom",
+			content: utf8BOM + input,
+			want:    utf8BOM + want,
+		},
+		{
+			name:    "bom_marker",
+			content: utf8BOM + "<!-- authors -->\n<!-- endauthors -->\n",
+			want: utf8BOM + "<!-- authors -->\n\n1. Arthur Dent\n2. Ford Prefect\n" +
+				"3. Tricia McMillan\n4. Marvin\n5. Zaphod Beeblebrox\n\n<!-- endauthors -->\n",
+		},
+		{
+			name:    "no_final_newline",
+			content: noFinal(crlf(input)),
+			want:    noFinal(crlf(want)),
+		},
+		{
+			name:    "mixed",
+			content: "# Authors\r\n\n<!-- authors -->\n<!-- endauthors -->\r\nFooter",
+			want: "# Authors\r\n\n<!-- authors -->\n" +
+				crlf("\n1. Arthur Dent\n2. Ford Prefect\n"+
+					"3. Tricia McMillan\n4. Marvin\n5. Zaphod Beeblebrox\n\n") +
+				"<!-- endauthors -->\r\nFooter",
+		},
+		{
+			name:    "unchanged",
+			content: "# Authors\r\n\nNo blocks.\n\r\n",
+			want:    "# Authors\r\n\nNo blocks.\n\r\n",
+		},
+		{
+			name:    "citation",
+			file:    "CITATION.cff",
+			content: utf8BOM + crlf("cff-version: 1.2.0\nauthors:\n  - given-names: Marvin\n"),
+			want: utf8BOM + crlf("cff-version: 1.2.0\nauthors:\n"+
+				"  - given-names: Marvin\n"+
+				"  - given-names: Arthur\n    family-names: Dent\n    email: dent@yahoo.com\n"+
+				"  - given-names: Ford\n    family-names: Prefect\n    email: ford@betelgeuse7.sid\n"+
+				"  - given-names: Tricia\n    family-names: McMillan\n"+
+				"    email: trillian@heartofgold.sid\n"+
+				"  - given-names: Zaphod\n    family-names: Beeblebrox\n"+
+				"    email: zaphod@heartofgold.sid\n"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := setupRepo(t)
+
+			file := tt.file
+			if file == "" {
+				file = "AUTHORS.md"
+			}
+			path := filepath.Join(dir, file)
+
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			conf := testConfig()
+			conf.Format = "{index}. {name}\\n"
+			conf.Append = tt.file != ""
+
+			if err := ProcessFile(path, conf); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			actual, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(actual) != tt.want {
+				t.Errorf("unexpected result:\n--- got:\n%q\n--- want:\n%q", actual, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessFileRewrite(t *testing.T) {
+	const block = "<!-- authors -->\n- Old\n<!-- endauthors -->\n"
+
+	// longer than default bufio.Scanner limit
+	longLine := "![img](data:image/png;base64," + strings.Repeat("A", 200*1024) + ")\n"
+
+	dir := setupRepo(t)
+	path := filepath.Join(dir, "AUTHORS.md")
+
+	if err := os.WriteFile(path, []byte(longLine+block+longLine), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(dir, "LINK.md")
+	if err := os.Symlink("AUTHORS.md", link); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := testConfig()
+	conf.Format = "{name}\\n"
+
+	if err := ProcessFile(link, conf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	actual, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(string(actual), longLine+"<!-- authors -->\n\nArthur Dent\n") ||
+		!strings.HasSuffix(string(actual), "<!-- endauthors -->\n"+longLine) {
+		t.Errorf("unexpected result")
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("unexpected permissions: got %v, want %v", info.Mode().Perm(), fs.FileMode(0600))
+	}
+
+	info, err = os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("symlink was replaced")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ent := range entries {
+		if strings.HasSuffix(ent.Name(), ".tmp") {
+			t.Errorf("temporary file left: %s", ent.Name())
+		}
+	}
+}
+
+func TestProcessFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errText string
+	}{
+		{
+			name:    "missing_end",
+			content: "# Authors\n\n<!-- authors -->\n\n- foo\n",
+			errText: "unpaired <!--authors-->/<!--endauthors--> at line 5",
+		},
+		{
+			name:    "missing_begin",
+			content: "# Authors\n\n- foo\n\n<!-- endauthors -->\n",
+			errText: "unpaired <!--authors-->/<!--endauthors--> at line 5",
+		},
+		{
+			name:    "nested_begin",
+			content: "<!-- authors -->\n<!-- authors -->\n<!-- endauthors -->\n",
+			errText: "unpaired <!--authors-->/<!--endauthors--> at line 2",
+		},
+		{
+			name:    "mismatched_end",
+			content: "<!-- authors -->\n<!-- endcontributors -->\n",
+			errText: "unpaired <!--contributors-->/<!--endcontributors--> at line 2",
+		},
+		{
+			name:    "bad_attr",
+			content: "<!-- authors format=nope -->\n<!-- endauthors -->\n",
+			errText: "unknown format \"nope\" at line 1",
+		},
+		{
+			name:    "bad_marker",
+			content: "<!-- authors -->\n<!-- endauthors -->\n",
+			errText: "bad marker",
+		},
+		{
+			name:    "bad_format",
+			content: "<!-- authors -->\n<!-- endauthors -->\n",
+			errText: "bad format spec",
+		},
+		{
+			name:    "bad_template",
+			content: "<!-- authors -->\n<!-- endauthors -->\n",
+			errText: "bad template",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := setupRepo(t)
+			path := filepath.Join(dir, "AUTHORS.md")
+
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			conf := testConfig()
+			switch tt.name {
+			case "bad_format":
+				conf.Format = "{index}. {nickname}\\n"
+			case "bad_template":
+				conf.Template = "{{.Index}}. {{.Nickname}}"
+			case "mismatched_end":
+				conf.MarkerNames = []string{"authors", "contributors"}
+			case "bad_marker":
+				conf.MarkerNames = []string{"auth(ors"}
+			}
+
+			err := ProcessFile(path, conf)
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			// file should be left untouched
+			actual, _ := os.ReadFile(path)
+			if string(actual) != tt.content {
+				t.Errorf("file was modified:\n%s", actual)
+			}
+		})
+	}
+}
+
+// Run ProcessPipe with given stdin and return its stdout.
+func runPipe(t *testing.T, stdin string, conf defs.Config) string {
+	t.Helper()
+
+	inFile := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(inFile, []byte(stdin), 0644); err != nil {
+		t.Fatal(err)
+	}
+	in, err := os.Open(inFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	outReader, outWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = in, outWriter
+
+	outCh := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(outReader)
+		outCh <- string(b)
+	}()
+
+	err = ProcessPipe(conf)
+
+	os.Stdin, os.Stdout = oldStdin, oldStdout
+	outWriter.Close()
+	out := <-outCh
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	return out
+}
+
+func TestProcessPipe(t *testing.T) {
+	tests := []struct {
+		name  string
+		stdin string
+		conf  func(*defs.Config)
+	}{
+		{
+			name: "pipe",
+			conf: func(c *defs.Config) {
+				c.Format = "{email};{login};{name}"
+			},
+		},
+		{
+			name:  "pipe_append",
+			stdin: "1. Arthur Dent\n2. Ford Prefect `Ix`\n3. marvin@sirius.cyb\n",
+			conf: func(c *defs.Config) {
+				c.Append = true
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			golden, _ := filepath.Abs(filepath.Join("testdata", tt.name+".golden.txt"))
+
+			setupRepo(t)
+
+			conf := testConfig()
+			conf.Pipe = true
+			if tt.conf != nil {
+				tt.conf(&conf)
+			}
+
+			checkGolden(t, golden, runPipe(t, tt.stdin, conf))
+		})
+	}
+}
+
+func TestProcessEscape(t *testing.T) {
+	tests := []struct {
+		name string
+		conf func(*defs.Config)
+	}{
+		{
+			name: "escape_auto",
+		},
+		{
+			name: "escape_html",
+			conf: func(c *defs.Config) {
+				c.Escape = "html"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, _ := filepath.Abs(filepath.Join("testdata", "escape.md"))
+			golden, _ := filepath.Abs(filepath.Join("testdata", tt.name+".golden.md"))
+
+			content, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dir := setupRepoCommits(t, testEscapeCommits)
+			path := filepath.Join(dir, "AUTHORS.md")
+
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			conf := testConfig()
+			conf.Format = "- {name} `{name}` (<{email}>)\\n"
+			if tt.conf != nil {
+				tt.conf(&conf)
+			}
+
+			if err := ProcessFile(path, conf); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			actual, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkGolden(t, golden, string(actual))
+
+			if tt.name == "escape_auto" {
+				for _, line := range []string{
+					"- Back\\`tick \\[Bob\\] `` Back`tick [Bob] `` (<bob@ticks.sid>)\n",
+					"- Tom Jerry & Co `Tom Jerry & Co` (<tom&jerry@cartoon.sid>)\n",
+				} {
+					if !strings.Contains(string(actual), line) {
+						t.Errorf("missing line %q", line)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("escape_pipe", func(t *testing.T) {
+		golden, _ := filepath.Abs(filepath.Join("testdata", "escape_pipe.golden.txt"))
+
+		setupRepoCommits(t, testEscapeCommits)
+
+		conf := testConfig()
+		conf.Pipe = true
+		conf.Format = "{name};{email}"
+
+		checkGolden(t, golden, runPipe(t, "", conf))
+	})
+}
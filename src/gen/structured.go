@@ -0,0 +1,355 @@
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gavv/md-authors/src/backend"
+	"github.com/gavv/md-authors/src/defs"
+	"github.com/gavv/md-authors/src/logs"
+)
+
+// Structured file that is updated as a whole, instead of
+// <!-- authors --> blocks. Detected by file name.
+type structuredFile struct {
+	name string
+	// Gets current contents, empty if file doesn't exist, and
+	// returns new contents. Should return data as is if nothing
+	// is changed, to keep formatting.
+	process func(data []byte, authors []defs.Author, conf defs.Config) ([]byte, error)
+}
+
+var structuredFiles = []structuredFile{
+	{allContribFile, processAllContrib},
+	{citationFile, processCitation},
+	{zenodoFile, processZenodo},
+	{packageJSONFile, processPackageJSON},
+	{cargoFile, processCargo},
+	{pyprojectFile, processPyproject},
+}
+
+func findStructuredFile(path string) *structuredFile {
+	for n := range structuredFiles {
+		if filepath.Base(path) == structuredFiles[n].name {
+			return &structuredFiles[n]
+		}
+	}
+
+	return nil
+}
+
+// Create or update structured file.
+func processStructuredFile(path string, sf *structuredFile, conf defs.Config) error {
+	logs.Debugf("processing %q", path)
+
+	oldData, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("can't read %q: %w", path, err)
+	}
+
+	authors, err := loadAuthors(filepath.Dir(path), conf)
+	if err != nil {
+		return fmt.Errorf("can't process %q: %w", path, err)
+	}
+
+	// process without BOM and CRLF, and restore them when writing
+	style := detectTextStyle(oldData)
+	decodedData := []byte(style.decode(string(oldData)))
+
+	newData, err := sf.process(decodedData, authors, conf)
+	if err != nil {
+		return fmt.Errorf("can't process %q: %w", path, err)
+	}
+
+	if bytes.Equal(decodedData, newData) {
+		logs.Infof("no new authors")
+		return nil
+	}
+
+	newData = []byte(style.encode(string(newData)))
+
+	if err := writeFileAtomic(path, newData); err != nil {
+		return fmt.Errorf("can't write %q: %w", path, err)
+	}
+
+	return nil
+}
+
+// Remove duplicate authors, i.e. with same login, email, profile,
+// or full name. First occurrence wins, but its last contribution
+// date is updated from duplicates.
+func uniqueAuthors(authors []defs.Author) []defs.Author {
+	var result []defs.Author
+
+	// key => index in result
+	seenKeys := make(map[string]int)
+
+	for _, author := range authors {
+		var keys []string
+
+		for _, key := range []string{author.Login, author.Email, author.Profile} {
+			if key != "" {
+				keys = append(keys, strings.ToLower(key))
+			}
+		}
+		if spaceRx.MatchString(strings.TrimSpace(author.Name)) {
+			keys = append(keys, strings.ToLower(author.Name))
+		}
+
+		idx := -1
+		for _, key := range keys {
+			if n, ok := seenKeys[key]; ok {
+				idx = n
+				break
+			}
+		}
+
+		if idx >= 0 {
+			logs.Debugf("dup: %s <%s> %s", author.Name, author.Email, author.Login)
+			if author.LastDate > result[idx].LastDate {
+				result[idx].LastDate = author.LastDate
+			}
+		} else {
+			result = append(result, author)
+			idx = len(result) - 1
+		}
+
+		for _, key := range keys {
+			if _, ok := seenKeys[key]; !ok {
+				seenKeys[key] = idx
+			}
+		}
+	}
+
+	return result
+}
+
+// Split full name into given names, name particle (like "van"
+// or "de"), and family name. Family name is the last word, and
+// particle is a sequence of lower-case words before it. Name
+// consisting of single word is treated as family name.
+func splitName(name string) (given, particle, family string) {
+	words := strings.Fields(name)
+	if len(words) == 0 {
+		return "", "", ""
+	}
+
+	family = words[len(words)-1]
+	words = words[:len(words)-1]
+
+	end := len(words)
+	for end > 1 {
+		if r, _ := utf8.DecodeRuneInString(words[end-1]); !unicode.IsLower(r) {
+			break
+		}
+		end--
+	}
+
+	given = strings.Join(words[:end], " ")
+	particle = strings.Join(words[end:], " ")
+
+	return given, particle, family
+}
+
+// Format JSON object from ordered lists of keys and raw values.
+func formatJSONObject(keys []string, values []json.RawMessage) ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString("{")
+	for n, key := range keys {
+		if n != 0 {
+			buf.WriteString(",")
+		}
+		buf.Write(marshalJSON(key))
+		buf.WriteString(":")
+		buf.Write(values[n])
+	}
+	buf.WriteString("}")
+
+	var result bytes.Buffer
+	if err := json.Indent(&result, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	result.WriteString("\n")
+
+	return result.Bytes(), nil
+}
+
+// Update JSON object from ordered lists of keys and raw values, keeping
+// original formatting of data. Only changed values are replaced, and new
+// keys are added to the end. Inserted values are indented the same way
+// as the rest of the file.
+func updateJSONObject(data []byte, keys []string, values []json.RawMessage) ([]byte, error) {
+	oldKeys, oldValues, spans, err := scanJSONObject(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(oldKeys) == 0 {
+		return formatJSONObject(keys, values)
+	}
+
+	indent := jsonIndentRx.FindSubmatch(data)
+
+	formatValue := func(value json.RawMessage) ([]byte, error) {
+		var buf bytes.Buffer
+		if indent == nil {
+			if err := json.Compact(&buf, value); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := json.Indent(&buf, value, string(indent[1]), string(indent[1])); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	}
+
+	var (
+		result  bytes.Buffer
+		pos     int
+		newTail bytes.Buffer
+	)
+
+	for n, key := range keys {
+		idx := slices.Index(oldKeys, key)
+		if idx >= 0 && bytes.Equal(oldValues[idx], values[n]) {
+			continue
+		}
+
+		value, err := formatValue(values[n])
+		if err != nil {
+			return nil, err
+		}
+
+		if idx >= 0 {
+			if spans[idx][0] < pos {
+				// keys were reordered, can't splice
+				return formatJSONObject(keys, values)
+			}
+			result.Write(data[pos:spans[idx][0]])
+			result.Write(value)
+			pos = spans[idx][1]
+		} else {
+			newTail.WriteString(",")
+			if indent == nil {
+				newTail.Write(marshalJSON(key))
+				newTail.WriteString(":")
+			} else {
+				newTail.WriteString("\n")
+				newTail.Write(indent[1])
+				newTail.Write(marshalJSON(key))
+				newTail.WriteString(": ")
+			}
+			newTail.Write(value)
+		}
+	}
+
+	// new keys go after last value
+	lastEnd := spans[len(spans)-1][1]
+	if pos > lastEnd {
+		lastEnd = pos
+	}
+	result.Write(data[pos:lastEnd])
+	result.Write(newTail.Bytes())
+	result.Write(data[lastEnd:])
+
+	return result.Bytes(), nil
+}
+
+// Matches indentation of first key in pretty-printed JSON object.
+var jsonIndentRx = regexp.MustCompile(`\n([ \t]+)"`)
+
+// Marshal value without escaping html characters, which
+// are common in urls.
+func marshalJSON(value any) json.RawMessage {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(value); err != nil {
+		// only used for values that are always encodable
+		panic(err)
+	}
+
+	return bytes.TrimSpace(buf.Bytes())
+}
+
+// Parse JSON object into ordered lists of keys and raw values.
+func parseJSONObject(data []byte) ([]string, []json.RawMessage, error) {
+	keys, values, _, err := scanJSONObject(data)
+
+	return keys, values, err
+}
+
+// Same as parseJSONObject, but also returns start and end
+// offsets of every value in data.
+func scanJSONObject(data []byte) ([]string, []json.RawMessage, [][2]int, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, nil, nil, fmt.Errorf("expected object")
+	}
+
+	var (
+		keys   []string
+		values []json.RawMessage
+		spans  [][2]int
+	)
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		key, _ := tok.(string)
+
+		// value follows colon and whitespace
+		pos := int(dec.InputOffset())
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, nil, nil, err
+		}
+
+		start := pos + bytes.Index(data[pos:], value)
+
+		keys = append(keys, key)
+		values = append(values, value)
+		spans = append(spans, [2]int{start, start + len(value)})
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return keys, values, spans, nil
+}
+
+// Get title for newly created file: github repository
+// name, or name of current directory.
+func projectTitle(conf defs.Config) string {
+	_, repo, _ := strings.Cut(backend.ProjectName(conf), "/")
+
+	if repo == "" {
+		if dir, err := os.Getwd(); err == nil {
+			repo = filepath.Base(dir)
+		}
+	}
+
+	return repo
+}
@@ -0,0 +1,279 @@
+package gen
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gavv/md-authors/src/defs"
+)
+
+// Authors for structured file tests.
+var testStructuredAuthors = []defs.Author{
+	{
+		Name:    "Arthur Dent",
+		Email:   "arthur@example.com",
+		Login:   "sandwich-maker",
+		Profile: "https://github.com/sandwich-maker",
+		Avatar:  "https://avatars.githubusercontent.com/u/42?s=64&v=4",
+	},
+	{
+		Name:    "Ford Prefect",
+		Email:   "ford@betelgeuse7.sid",
+		Login:   "ix",
+		Profile: "https://github.com/Ix",
+		Avatar:  "https://avatars.githubusercontent.com/u/1234?s=64&v=4",
+	},
+	{
+		Name:  "Arthur Dent",
+		Email: "dent@old.example.com",
+	},
+	{
+		Name: "Marvin",
+	},
+	{
+		Name:    "Tricia McMillan",
+		Login:   "trillian",
+		Profile: "https://github.com/trillian",
+		Roles:   []string{"design"},
+	},
+	{
+		Name:  "Hig van Hurtenflurst",
+		Email: "hig@vogon.gov",
+	},
+}
+
+func TestProcessStructured(t *testing.T) {
+	tests := []struct {
+		// file in testdata, or empty to create new file
+		input string
+		// golden files are <name>.golden<ext> and <name>_append.golden<ext>
+		golden  string
+		process func([]byte, []defs.Author, defs.Config) ([]byte, error)
+	}{
+		{"", "allcontrib_create.json", processAllContrib},
+		{"allcontrib.json", "allcontrib.json", processAllContrib},
+		{"", "citation_create.cff", processCitation},
+		{"citation.cff", "citation.cff", processCitation},
+		{"", "zenodo_create.json", processZenodo},
+		{"zenodo.json", "zenodo.json", processZenodo},
+		{"package.json", "package.json", processPackageJSON},
+		{"package_tabs.json", "package_tabs.json", processPackageJSON},
+		{"Cargo.toml", "Cargo.toml", processCargo},
+		{"Cargo_workspace.toml", "Cargo_workspace.toml", processCargo},
+		{"pyproject.toml", "pyproject.toml", processPyproject},
+		{"pyproject_poetry.toml", "pyproject_poetry.toml", processPyproject},
+	}
+
+	for _, tt := range tests {
+		ext := filepath.Ext(tt.golden)
+		base := strings.TrimSuffix(tt.golden, ext)
+
+		for _, appendMode := range []bool{false, true} {
+			name := base
+			if appendMode {
+				name += "_append"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				golden, _ := filepath.Abs(filepath.Join("testdata", name+".golden"+ext))
+
+				var oldData []byte
+				if tt.input != "" {
+					var err error
+					oldData, err = os.ReadFile(filepath.Join("testdata", tt.input))
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				conf := defs.Config{
+					Append:    appendMode,
+					Project:   "magrathea/earth",
+					NoProject: true,
+				}
+
+				data, err := tt.process(oldData, testStructuredAuthors, conf)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+
+				checkGolden(t, golden, string(data))
+
+				// second update should be no-op
+				newData, err := tt.process(data, testStructuredAuthors, conf)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if string(newData) != string(data) {
+					t.Errorf("second update modified file:\n%s", newData)
+				}
+			})
+		}
+	}
+}
+
+func TestUpdateJSONObject(t *testing.T) {
+	tests := []struct {
+		data   string
+		keys   []string
+		values []string
+		want   string
+	}{
+		// compact
+		{
+			`{"a":1, "b":[1, 2]}`,
+			[]string{"a", "b", "c"},
+			[]string{`1`, `[ 3 ]`, `{"d": 4}`},
+			`{"a":1, "b":[3],"c":{"d":4}}`,
+		},
+		// four spaces
+		{
+			"{\n    \"a\": [1, 2],\n    \"b\": [\n        1\n    ]\n}\n",
+			[]string{"a", "b"},
+			[]string{`[1, 2]`, `[1,2]`},
+			"{\n    \"a\": [1, 2],\n    \"b\": [\n        1,\n        2\n    ]\n}\n",
+		},
+		// tabs, new key
+		{
+			"{\n\t\"a\": [1, 2]\n}\n",
+			[]string{"a", "b"},
+			[]string{`[1, 2]`, `["x"]`},
+			"{\n\t\"a\": [1, 2],\n\t\"b\": [\n\t\t\"x\"\n\t]\n}\n",
+		},
+		// empty object
+		{
+			"{}",
+			[]string{"a"},
+			[]string{`[1]`},
+			"{\n  \"a\": [\n    1\n  ]\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var values []json.RawMessage
+		for _, value := range tt.values {
+			values = append(values, json.RawMessage(value))
+		}
+
+		got, err := updateJSONObject([]byte(tt.data), tt.keys, values)
+		if err != nil {
+			t.Errorf("updateJSONObject(%q): unexpected error: %s", tt.data, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("updateJSONObject(%q):\n got: %q\nwant: %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestSplitName(t *testing.T) {
+	tests := []struct {
+		name                    string
+		given, particle, family string
+	}{
+		{"", "", "", ""},
+		{"Marvin", "", "", "Marvin"},
+		{"Arthur Dent", "Arthur", "", "Dent"},
+		{"  Arthur   Philip  Dent ", "Arthur Philip", "", "Dent"},
+		{"Ludwig van Beethoven", "Ludwig", "van", "Beethoven"},
+		{"Leonardo di ser Piero", "Leonardo", "di ser", "Piero"},
+		{"van Gogh", "van", "", "Gogh"},
+	}
+
+	for _, tt := range tests {
+		given, particle, family := splitName(tt.name)
+		if given != tt.given || particle != tt.particle || family != tt.family {
+			t.Errorf("splitName(%q): got (%q, %q, %q), want (%q, %q, %q)",
+				tt.name, given, particle, family, tt.given, tt.particle, tt.family)
+		}
+	}
+}
+
+func TestUniqueAuthors(t *testing.T) {
+	var names []string
+	for _, author := range uniqueAuthors(testStructuredAuthors) {
+		names = append(names, author.Name)
+	}
+
+	want := "Arthur Dent, Ford Prefect, Marvin, Tricia McMillan, Hig van Hurtenflurst"
+	if got := strings.Join(names, ", "); got != want {
+		t.Errorf("unexpected result: got %q, want %q", got, want)
+	}
+}
+
+// Commits with GitHub noreply emails, so that logins become known
+// only after authors are populated.
+var testLoginCommits = append(slices.Clone(testCommits),
+	"2020-04-01;Renovate Bot;29139614+renovate[bot]@users.noreply.github.com",
+	"2020-05-01;Slartibartfast;1234+slarti@users.noreply.github.com",
+	"2020-06-01;Hotblack Desiato;4242+hotblack@users.noreply.github.com",
+)
+
+func TestProcessStructuredFilter(t *testing.T) {
+	// github api stub that knows nothing
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("content-type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+		}))
+	defer server.Close()
+
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{allContribFile, ""},
+		{"CITATION.cff", ""},
+		{".zenodo.json", ""},
+		{"package.json", "{\n  \"name\": \"earth\"\n}\n"},
+		{"Cargo.toml", "[package]\nname = \"earth\"\n"},
+		{"pyproject.toml", "[project]\nname = \"earth\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			dir := setupRepoCommits(t, testLoginCommits)
+			path := filepath.Join(dir, tt.file)
+
+			// if content is empty, file is created
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			conf := testConfig()
+			conf.NoProject = false
+			conf.Project = "magrathea/earth"
+			conf.GithubAPI = server.URL
+			conf.Token = "test"
+			conf.Ignore = []string{"slarti"}
+
+			if err := ProcessFile(path, conf); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := strings.ToLower(string(data))
+
+			if !strings.Contains(text, "hotblack") {
+				t.Errorf("missing author:\n%s", data)
+			}
+			for _, name := range []string{"renovate", "slarti"} {
+				if strings.Contains(text, name) {
+					t.Errorf("unexpected author %q:\n%s", name, data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gavv/md-authors/src/defs"
+)
+
+// Prefix of --format value that is a Go template instead of format spec.
+const templatePrefix = "tmpl:"
+
+// Names of optional templates that may be defined by user.
+const (
+	// Renders the whole block at once.
+	blockTemplate = "block"
+	// Rendered once before and after entries.
+	headerTemplate = "header"
+	footerTemplate = "footer"
+	// Renders group key of author, and group header when key changes.
+	groupByTemplate = "groupby"
+	groupTemplate   = "group"
+)
+
+// Compiled Go text/template.
+//
+// By default, template is executed for every author, with defs.Author
+// as data. Optional "header" and "footer" templates are executed before
+// and after entries, with list of authors as data. If "groupby" template
+// is defined, its output is used as group key, and "group" template is
+// executed every time when key changes, with templateGroup as data.
+//
+// If template defines "block" template, it is instead executed once,
+// with list of all new authors as data.
+type formatTemplate struct {
+	tmpl *template.Template
+}
+
+// Data for "group" template.
+type templateGroup struct {
+	Key     string
+	Authors []defs.Author
+}
+
+// Defaults for avatarSize and avatarColumns, used when config
+// doesn't set them, e.g. when called as a library.
+const (
+	defaultAvatarSize    = 64
+	defaultAvatarColumns = 8
+)
+
+// Functions available in templates.
+var templateFuncs = template.FuncMap{
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+	"truncate": truncateText,
+	"date":     formatDate,
+	"mdescape": markdownEscape,
+	"mod":      modulo,
+}
+
+func compileTemplate(text string, conf defs.Config) (*formatTemplate, error) {
+	avatarSize := conf.AvatarSize
+	if avatarSize < 1 {
+		avatarSize = defaultAvatarSize
+	}
+	avatarColumns := conf.AvatarColumns
+	if avatarColumns < 1 {
+		avatarColumns = defaultAvatarColumns
+	}
+
+	tmpl, err := template.New("entry").
+		Funcs(templateFuncs).
+		Funcs(template.FuncMap{
+			"avatarSize":    func() int { return avatarSize },
+			"avatarColumns": func() int { return avatarColumns },
+			"escape": func(text string) string {
+				return escapeValue(conf.Escape, contextText, "", text)
+			},
+		}).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("bad template: %w", err)
+	}
+
+	if tmpl.Lookup(groupTemplate) != nil && tmpl.Lookup(groupByTemplate) == nil {
+		return nil, fmt.Errorf("bad template: %q requires %q to be defined",
+			groupTemplate, groupByTemplate)
+	}
+
+	return &formatTemplate{tmpl: tmpl}, nil
+}
+
+// Count entries in existing block content.
+// Header, footer, and group header lines are not counted.
+func (ft *formatTemplate) countEntries(content string, authors []defs.Author) int {
+	if ft.tmpl.Lookup(blockTemplate) != nil {
+		return countLines(content)
+	}
+
+	skipLines := make(map[string]bool)
+
+	addLines := func(text string) {
+		for _, line := range strings.Split(text, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				skipLines[line] = true
+			}
+		}
+	}
+
+	if text, err := ft.execute(headerTemplate, authors); err == nil {
+		addLines(text)
+	}
+	if text, err := ft.execute(footerTemplate, authors); err == nil {
+		addLines(text)
+	}
+	if groups, err := ft.groupAuthors(authors); err == nil {
+		for _, group := range groups {
+			if text, err := ft.execute(groupTemplate, group); err == nil {
+				addLines(text)
+			}
+		}
+	}
+
+	count := 0
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !skipLines[line] {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Format list of new authors.
+//
+// If content is empty, renders header, entries, and footer.
+// Otherwise (in --append mode), renders only entries, and inserts
+// them before the footer.
+func (ft *formatTemplate) formatAuthors(
+	content string, authors []defs.Author,
+) (int, string, error) {
+	if len(authors) == 0 {
+		return len(content), "", nil
+	}
+
+	if ft.tmpl.Lookup(blockTemplate) != nil {
+		text, err := ft.execute(blockTemplate, authors)
+		if err != nil {
+			return 0, "", err
+		}
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		return len(content), text, nil
+	}
+
+	var b strings.Builder
+
+	isEmpty := strings.TrimSpace(content) == ""
+
+	header, err := ft.execute(headerTemplate, authors)
+	if err != nil {
+		return 0, "", err
+	}
+	footer, err := ft.execute(footerTemplate, authors)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if isEmpty {
+		b.WriteString(header)
+	}
+
+	groups, err := ft.groupAuthors(authors)
+	if err != nil {
+		return 0, "", err
+	}
+
+	firstEntry := ""
+
+	for n, group := range groups {
+		groupHeader, err := ft.execute(groupTemplate, group)
+		if err != nil {
+			return 0, "", err
+		}
+		// when appending, don't repeat header of last existing group
+		if n != 0 || isEmpty || !strings.Contains(content, strings.TrimSpace(groupHeader)) {
+			b.WriteString(groupHeader)
+		}
+
+		for _, author := range group.Authors {
+			entry, err := ft.execute("entry", author)
+			if err != nil {
+				return 0, "", err
+			}
+			// same as for format spec
+			if !strings.Contains(entry, "\n") {
+				entry += "\n"
+			}
+			if firstEntry == "" {
+				firstEntry = entry
+			}
+			b.WriteString(entry)
+		}
+	}
+
+	pos := len(content)
+
+	if isEmpty {
+		b.WriteString(footer)
+	} else {
+		pos = findFooter(content, footer)
+		// rows of markdown table must be contiguous, so new rows
+		// go right after existing ones, before any trailing text
+		if strings.HasPrefix(strings.TrimSpace(firstEntry), "|") {
+			pos = findTableEnd(content[:pos])
+		}
+	}
+
+	return pos, b.String(), nil
+}
+
+// Split authors into groups by "groupby" template.
+// If it's not defined, returns single group.
+func (ft *formatTemplate) groupAuthors(authors []defs.Author) ([]templateGroup, error) {
+	if ft.tmpl.Lookup(groupByTemplate) == nil {
+		return []templateGroup{{Authors: authors}}, nil
+	}
+
+	var groups []templateGroup
+
+	for _, author := range authors {
+		key, err := ft.execute(groupByTemplate, author)
+		if err != nil {
+			return nil, err
+		}
+		key = strings.TrimSpace(key)
+
+		if len(groups) == 0 || groups[len(groups)-1].Key != key {
+			groups = append(groups, templateGroup{Key: key})
+		}
+		groups[len(groups)-1].Authors = append(groups[len(groups)-1].Authors, author)
+	}
+
+	return groups, nil
+}
+
+// Execute named template.
+// If it's not defined, returns empty string.
+func (ft *formatTemplate) execute(name string, data any) (string, error) {
+	tmpl := ft.tmpl.Lookup(name)
+	if tmpl == nil {
+		return "", nil
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("bad template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+// Find position of footer in content.
+// Footer is assumed to occupy as many trailing non-blank lines
+// as rendered footer has.
+func findFooter(content string, footer string) int {
+	n := countLines(footer)
+	pos := len(content)
+
+	for n > 0 && pos > 0 {
+		start := strings.LastIndexByte(content[:pos-1], '\n') + 1
+		if strings.TrimSpace(content[start:pos]) != "" {
+			n--
+		}
+		pos = start
+	}
+
+	return pos
+}
+
+// Find position after last row of markdown table in content.
+// If there are no rows, returns end of content.
+func findTableEnd(content string) int {
+	pos := len(content)
+
+	for pos > 0 {
+		start := strings.LastIndexByte(content[:pos-1], '\n') + 1
+		if strings.HasPrefix(strings.TrimSpace(content[start:pos]), "|") {
+			return pos
+		}
+		pos = start
+	}
+
+	return len(content)
+}
+
+// Remainder of division, or zero if divisor is zero.
+func modulo(a, b int) int {
+	if b == 0 {
+		return 0
+	}
+
+	return a % b
+}
+
+// Truncate text to given number of characters.
+func truncateText(length int, text string) string {
+	if length < 0 || utf8.RuneCountInString(text) <= length {
+		return text
+	}
+
+	runes := []rune(text)
+
+	return string(runes[:length])
+}
+
+// Reformat date from "YYYY-MM-DD" into given Go layout.
+// If date can't be parsed, it's returned as is.
+func formatDate(layout string, date string) string {
+	t, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return date
+	}
+
+	return t.Format(layout)
+}
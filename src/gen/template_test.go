@@ -0,0 +1,118 @@
+package gen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gavv/md-authors/src/defs"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"truncate", truncateText(5, "Zaphod"), "Zapho"},
+		{"truncate_short", truncateText(10, "Zaphod"), "Zaphod"},
+		{"truncate_unicode", truncateText(2, "Ñoño"), "Ño"},
+		{"date", formatDate("Jan 2006", "2021-06-01"), "Jun 2021"},
+		{"date_invalid", formatDate("Jan 2006", "someday"), "someday"},
+		{"mdescape", markdownEscape("a|b *c* [d]"), `a\|b \*c\* \[d\]`},
+		{"mdescape_plain", markdownEscape("Ford Prefect"), "Ford Prefect"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvatarsTemplate(t *testing.T) {
+	// zero config, like when used as a library
+	ft, err := compileTemplate(
+		strings.TrimPrefix(BuiltinFormats["avatars"], templatePrefix), defs.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var authors []defs.Author
+	for n := 1; n <= 9; n++ {
+		authors = append(authors, defs.Author{
+			Index:   n,
+			Name:    "Tom & Jerry",
+			Login:   `tom"jerry`,
+			Profile: "https://example.com/?u=tom&x=1",
+			Avatar:  `https://example.com/a.png?s=64&v="4"`,
+		})
+	}
+
+	_, text, err := ft.formatAuthors("", authors)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if len(lines) != 9 {
+		t.Fatalf("unexpected number of lines: %d", len(lines))
+	}
+
+	want := `<a href="https://example.com/?u=tom&amp;x=1">` +
+		`<img src="https://example.com/a.png?s=64&amp;v=&#34;4&#34;" width="64" height="64"` +
+		` alt="tom&#34;jerry" title="Tom &amp; Jerry"></a>`
+
+	for n, line := range lines {
+		// row break after every 8 entries by default
+		wantLine := want
+		if n == 7 {
+			wantLine += "<br>"
+		}
+		if line != wantLine {
+			t.Errorf("line %d:\n got: %s\nwant: %s", n+1, line, wantLine)
+		}
+	}
+}
+
+func TestTableTemplate(t *testing.T) {
+	ft, err := compileTemplate(
+		strings.TrimPrefix(BuiltinFormats["table"], templatePrefix), defs.Config{
+			AvatarSize: 32,
+		})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	authors := []defs.Author{
+		{
+			Name:    "Ford Prefect",
+			Login:   "Ix",
+			Profile: "https://ghe.example.com/Ix",
+			Avatar:  "https://ghe.example.com/avatars/u/1234?s=32&v=4",
+			Date:    "2020-02-01",
+		},
+	}
+
+	row := `| <img src="https://ghe.example.com/avatars/u/1234?s=32&amp;v=4" width="32" height="32">` +
+		` | Ford Prefect | [@Ix](https://ghe.example.com/Ix) | 2020-02-01 |` + "\n"
+
+	content := "|   | Name | GitHub | Since |\n" +
+		"|---|------|--------|-------|\n" +
+		"|  | Marvin |  | 2019-01-01 |\n" +
+		"\n" +
+		"Avatars are provided by GitHub.\n"
+
+	// when appending, row is inserted after last row of the table
+	pos, text, err := ft.formatAuthors(content, authors)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if text != row {
+		t.Errorf("unexpected row:\n got: %q\nwant: %q", text, row)
+	}
+	if want := strings.Index(content, "\n\n") + 1; pos != want {
+		t.Errorf("unexpected position: got %d, want %d", pos, want)
+	}
+}
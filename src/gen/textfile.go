@@ -0,0 +1,116 @@
+package gen
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UTF-8 byte order mark.
+const utf8BOM = "\uFEFF"
+
+// Encoding details of text file, preserved when rewriting it.
+type textStyle struct {
+	bom  bool
+	crlf bool
+}
+
+// Detect BOM and newline style.
+// Newline style is determined by the first line.
+func detectTextStyle(data []byte) textStyle {
+	var ts textStyle
+
+	ts.bom = bytes.HasPrefix(data, []byte(utf8BOM))
+
+	if pos := bytes.IndexByte(data, '\n'); pos > 0 && data[pos-1] == '\r' {
+		ts.crlf = true
+	}
+
+	return ts
+}
+
+// Convert "\n" newlines to file style.
+func (ts textStyle) newlines(text string) string {
+	if ts.crlf {
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	}
+
+	return text
+}
+
+// Convert whole file contents to file style, assuming it
+// uses "\n" newlines.
+func (ts textStyle) encode(text string) string {
+	text = ts.newlines(text)
+	if ts.bom {
+		text = utf8BOM + text
+	}
+
+	return text
+}
+
+// Convert text from file style, removing BOM and "\r" before newlines.
+// Can be also used for the first line.
+func (ts textStyle) decode(text string) string {
+	text = strings.TrimPrefix(text, utf8BOM)
+	if ts.crlf {
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+	}
+
+	return text
+}
+
+// Split line into text and terminator ("\n", "\r\n", or empty
+// for last line without newline).
+func splitLineEnding(line string) (string, string) {
+	switch {
+	case strings.HasSuffix(line, "\r\n"):
+		return line[:len(line)-2], "\r\n"
+	case strings.HasSuffix(line, "\n"):
+		return line[:len(line)-1], "\n"
+	}
+
+	return line, ""
+}
+
+// Atomically replace file contents.
+// Data is written to a temporary file in the same directory, which
+// is then renamed to path, so that the file is never left truncated.
+// Permissions of existing file are preserved, and if path is
+// a symlink, its target is replaced.
+func writeFileAtomic(path string, data []byte) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
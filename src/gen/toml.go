@@ -0,0 +1,342 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gavv/md-authors/src/defs"
+)
+
+var (
+	// [table] or [[array.of.tables]]
+	tomlHeaderRx = regexp.MustCompile(`(?m)^[ \t]*\[(\[?)([^\[\]\n]+)\]\]?[ \t]*(?:#.*)?$`)
+	// authors = ...
+	tomlAuthorsRx = regexp.MustCompile(`(?m)^[ \t]*authors[ \t]*=[ \t]*`)
+	// authors.workspace = true
+	tomlInheritRx = regexp.MustCompile(`(?m)^[ \t]*authors[ \t]*\.`)
+	// key = "value" pair of inline table
+	tomlPairRx = regexp.MustCompile(`([\w-]+|"[^"]*")\s*=\s*("(?:[^"\\]|\\.)*"|'[^']*')`)
+)
+
+// Table in TOML manifest with "authors" array.
+type tomlTarget struct {
+	table  string
+	parse  func(raw string) manifestEntry
+	format func(author defs.Author) manifestEntry
+}
+
+// Update "authors" in [package] or [workspace.package] table of Cargo.toml.
+// If package inherits authors from workspace, the latter is updated.
+func processCargo(data []byte, authors []defs.Author, conf defs.Config) ([]byte, error) {
+	return updateTOMLAuthors(data, authors, conf.Append, []tomlTarget{
+		{
+			table:  "package",
+			parse:  parseTOMLPerson,
+			format: formatTOMLPerson,
+		},
+		{
+			table:  "workspace.package",
+			parse:  parseTOMLPerson,
+			format: formatTOMLPerson,
+		},
+	})
+}
+
+// Update "authors" in [project] table of pyproject.toml, as defined
+// by PEP 621, or in [tool.poetry] table, used by older Poetry versions.
+func processPyproject(data []byte, authors []defs.Author, conf defs.Config) ([]byte, error) {
+	return updateTOMLAuthors(data, authors, conf.Append, []tomlTarget{
+		{
+			table:  "project",
+			parse:  parseTOMLTable,
+			format: formatTOMLTable,
+		},
+		{
+			table:  "tool.poetry",
+			parse:  parseTOMLPerson,
+			format: formatTOMLPerson,
+		},
+	})
+}
+
+// Replace or append to "authors" array in first of the given tables
+// that has it, or add it to first existing table.
+// The rest of the file is kept as is.
+func updateTOMLAuthors(
+	data []byte, authors []defs.Author, appendMode bool, targets []tomlTarget,
+) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("file is empty or doesn't exist")
+	}
+
+	text := string(data)
+
+	var (
+		target               *tomlTarget
+		tableStart, tableEnd int
+		keyPos               []int
+	)
+
+	headers := tomlHeaderRx.FindAllStringSubmatchIndex(text, -1)
+
+	for n := range targets {
+		for h, m := range headers {
+			isArray := m[3] > m[2]
+			name := strings.NewReplacer(" ", "", "\t", "", `"`, "").Replace(text[m[4]:m[5]])
+			if isArray || name != targets[n].table {
+				continue
+			}
+
+			start, end := m[1], len(text)
+			if h+1 < len(headers) {
+				end = headers[h+1][0]
+			}
+
+			if tomlInheritRx.MatchString(text[start:end]) {
+				// inherited from other table
+				break
+			}
+
+			pos := tomlAuthorsRx.FindStringIndex(text[start:end])
+
+			if target == nil || (keyPos == nil && pos != nil) {
+				target, tableStart, tableEnd, keyPos = &targets[n], start, end, nil
+				if pos != nil {
+					keyPos = []int{start + pos[0], start + pos[1]}
+				}
+			}
+			break
+		}
+		if keyPos != nil {
+			break
+		}
+	}
+
+	if target == nil {
+		var names []string
+		for _, t := range targets {
+			names = append(names, "["+t.table+"]")
+		}
+		return nil, fmt.Errorf("can't find %s table", strings.Join(names, " or "))
+	}
+
+	var (
+		oldEntries []manifestEntry
+		valueStart int
+		valueEnd   int
+	)
+
+	if keyPos != nil {
+		valueStart = keyPos[1]
+
+		elements, end, err := parseTOMLArray(text, valueStart)
+		if err != nil {
+			lineNo := strings.Count(text[:valueStart], "\n") + 1
+			return nil, fmt.Errorf("unsupported format of authors at line %d: %w", lineNo, err)
+		}
+		valueEnd = end
+
+		for _, elem := range elements {
+			entry := target.parse(elem)
+			entry.raw = elem
+			oldEntries = append(oldEntries, entry)
+		}
+	}
+
+	newEntries, changed := mergeManifest(oldEntries, authors, target.format, appendMode)
+
+	if !changed {
+		// nothing changed, keep formatting
+		return data, nil
+	}
+
+	value := formatTOMLArray(newEntries)
+
+	if keyPos != nil {
+		return []byte(text[:valueStart] + value + text[valueEnd:]), nil
+	}
+
+	// insert new key after last non-blank line of table
+	pos := tableStart + len(strings.TrimRight(text[tableStart:tableEnd], " \t\n"))
+	if pos < len(text) && text[pos] == '\n' {
+		pos++
+	}
+
+	line := "authors = " + value + "\n"
+	if pos > 0 && text[pos-1] != '\n' {
+		line = "\n" + line
+	}
+
+	return []byte(text[:pos] + line + text[pos:]), nil
+}
+
+// Parse TOML array starting at given position.
+// Returns raw elements, without comments, and position after array.
+func parseTOMLArray(text string, pos int) ([]string, int, error) {
+	if pos >= len(text) || text[pos] != '[' {
+		return nil, 0, fmt.Errorf("expected array")
+	}
+
+	var (
+		elements []string
+		elem     strings.Builder
+		depth    int
+	)
+
+	for pos < len(text) {
+		c := text[pos]
+
+		switch {
+		case c == '"' || c == '\'':
+			end := findTOMLStringEnd(text, pos)
+			if end < 0 {
+				return nil, 0, fmt.Errorf("unterminated string")
+			}
+			elem.WriteString(text[pos:end])
+			pos = end
+			continue
+
+		case c == '#':
+			for pos < len(text) && text[pos] != '\n' {
+				pos++
+			}
+			continue
+
+		case c == '[' || c == '{':
+			depth++
+			if depth == 1 {
+				pos++
+				continue
+			}
+
+		case c == ']' || c == '}':
+			depth--
+			if depth == 0 {
+				if s := strings.TrimSpace(elem.String()); s != "" {
+					elements = append(elements, s)
+				}
+				return elements, pos + 1, nil
+			}
+
+		case c == ',' && depth == 1:
+			if s := strings.TrimSpace(elem.String()); s != "" {
+				elements = append(elements, s)
+			}
+			elem.Reset()
+			pos++
+			continue
+		}
+
+		elem.WriteByte(c)
+		pos++
+	}
+
+	return nil, 0, fmt.Errorf("unterminated array")
+}
+
+// Find position after TOML string starting at given position.
+// Handles basic, literal, and multi-line strings.
+func findTOMLStringEnd(text string, pos int) int {
+	quote := text[pos : pos+1]
+	if strings.HasPrefix(text[pos:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+
+	for n := pos + len(quote); n < len(text); n++ {
+		if text[n] == '\\' && quote[0] == '"' {
+			n++
+			continue
+		}
+		if strings.HasPrefix(text[n:], quote) {
+			return n + len(quote)
+		}
+	}
+
+	return -1
+}
+
+// Format array with one element per line, or on single line
+// if there is only one element.
+func formatTOMLArray(entries []manifestEntry) string {
+	switch len(entries) {
+	case 0:
+		return "[]"
+	case 1:
+		return "[" + entries[0].raw + "]"
+	}
+
+	var b strings.Builder
+
+	b.WriteString("[\n")
+	for _, entry := range entries {
+		b.WriteString("    " + entry.raw + ",\n")
+	}
+	b.WriteString("]")
+
+	return b.String()
+}
+
+// Parse "Name <email>" string element.
+func parseTOMLPerson(raw string) manifestEntry {
+	return parsePerson(tomlValue(raw))
+}
+
+// Format "Name <email>" string element.
+func formatTOMLPerson(author defs.Author) manifestEntry {
+	return manifestEntry{
+		raw: tomlString(formatPerson(manifestName(author), author.Email, "")),
+	}
+}
+
+// Parse {name = "...", email = "..."} inline table element.
+func parseTOMLTable(raw string) manifestEntry {
+	var entry manifestEntry
+
+	for _, m := range tomlPairRx.FindAllStringSubmatch(raw, -1) {
+		switch strings.Trim(m[1], `"`) {
+		case "name":
+			entry.name = tomlValue(m[2])
+		case "email":
+			entry.email = tomlValue(m[2])
+		}
+	}
+
+	return entry
+}
+
+// Format {name = "...", email = "..."} inline table element.
+func formatTOMLTable(author defs.Author) manifestEntry {
+	pairs := []string{"name = " + tomlString(manifestName(author))}
+
+	if author.Email != "" {
+		pairs = append(pairs, "email = "+tomlString(author.Email))
+	}
+
+	return manifestEntry{
+		raw: "{" + strings.Join(pairs, ", ") + "}",
+	}
+}
+
+// Format TOML basic string.
+func tomlString(s string) string {
+	// json string escapes are also valid in toml
+	return string(marshalJSON(s))
+}
+
+// Parse TOML basic or literal string.
+func tomlValue(s string) string {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		if value, err := strconv.Unquote(s); err == nil {
+			return value
+		}
+		return strings.Trim(s, `"`)
+	case strings.HasPrefix(s, `'`):
+		return strings.Trim(s, `'`)
+	}
+
+	return s
+}
@@ -0,0 +1,52 @@
+package gen
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gavv/md-authors/src/defs"
+)
+
+func TestProcessTOMLErrors(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"[dependencies]\nmice = \"1.0\"\n",
+		"[package]\nauthors = \"Marvin\"\n",
+		"[package]\nauthors = [\"Marvin\"\n",
+		"[package]\nauthors = [\"Marvin]\n",
+	} {
+		if _, err := processCargo([]byte(data), testStructuredAuthors,
+			defs.Config{}); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestParseTOMLArray(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{`[]`, nil},
+		{`["a"]`, []string{`"a"`}},
+		{`[ "a" , 'b', ]`, []string{`"a"`, `'b'`}},
+		{"[\n  \"a\", # comment, with [brackets]\n  \"b\"\n]", []string{`"a"`, `"b"`}},
+		{`["a, ]b", 'c\']`, []string{`"a, ]b"`, `'c\'`}},
+		{`["a\"]"]`, []string{`"a\"]"`}},
+		{`[{name = "a", email = "b"}, {name = "c"}]`,
+			[]string{`{name = "a", email = "b"}`, `{name = "c"}`}},
+		{`[["a", "b"], "c"]`, []string{`["a", "b"]`, `"c"`}},
+	}
+
+	for _, tt := range tests {
+		got, end, err := parseTOMLArray(tt.input+" # tail", 0)
+		if err != nil {
+			t.Errorf("parseTOMLArray(%q): unexpected error: %s", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) || end != len(tt.input) {
+			t.Errorf("parseTOMLArray(%q): got %q, %d, want %q, %d",
+				tt.input, got, end, tt.want, len(tt.input))
+		}
+	}
+}
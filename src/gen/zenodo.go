@@ -0,0 +1,182 @@
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gavv/md-authors/src/defs"
+	"github.com/gavv/md-authors/src/logs"
+)
+
+// Zenodo deposit metadata.
+// See https://developers.zenodo.org/#representation
+const zenodoFile = ".zenodo.json"
+
+// Entry of "creators" array.
+type zenodoCreator struct {
+	Name string `json:"name"`
+}
+
+// Create or update .zenodo.json file.
+func processZenodo(data []byte, authors []defs.Author, conf defs.Config) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return createZenodo(authors, projectTitle(conf))
+	}
+
+	return updateZenodo(data, authors, conf.Append)
+}
+
+// Generate minimal .zenodo.json.
+func createZenodo(authors []defs.Author, title string) ([]byte, error) {
+	keys := []string{
+		"title",
+		"upload_type",
+		"creators",
+	}
+	values := []json.RawMessage{
+		marshalJSON(title),
+		marshalJSON("software"),
+		marshalJSON([]any{}),
+	}
+
+	keys, values, _, err := mergeZenodo(keys, values, authors, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return formatJSONObject(keys, values)
+}
+
+// Update "creators" array in existing .zenodo.json.
+//
+// Existing entries are matched with authors by name and are kept
+// as is, including fields like orcid and affiliation. If appendMode
+// is true, all existing entries are kept, and new authors are added
+// to the end. Otherwise, array is rebuilt in the order of authors.
+func updateZenodo(data []byte, authors []defs.Author, appendMode bool) ([]byte, error) {
+	keys, values, err := parseJSONObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+
+	keys, values, changed, err := mergeZenodo(keys, values, authors, appendMode)
+	if err != nil {
+		return nil, err
+	}
+
+	if !changed {
+		// nothing changed, keep formatting
+		return data, nil
+	}
+
+	return updateJSONObject(data, keys, values)
+}
+
+// Build new "creators" array.
+// Returns updated keys and values, and whether array was changed.
+func mergeZenodo(
+	keys []string, values []json.RawMessage, authors []defs.Author, appendMode bool,
+) ([]string, []json.RawMessage, bool, error) {
+	var oldEntries []json.RawMessage
+
+	idx := -1
+	for n, key := range keys {
+		if key == "creators" {
+			idx = n
+			if err := json.Unmarshal(values[n], &oldEntries); err != nil {
+				return nil, nil, false, fmt.Errorf("invalid creators: %w", err)
+			}
+		}
+	}
+	if idx < 0 {
+		keys = append(keys, "creators")
+		values = append(values, nil)
+		idx = len(keys) - 1
+	}
+
+	var oldNames []string
+
+	for _, raw := range oldEntries {
+		var entry zenodoCreator
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return nil, nil, false, fmt.Errorf("invalid creators: %w", err)
+		}
+		oldNames = append(oldNames, parseZenodoName(entry.Name))
+	}
+
+	var newEntries []json.RawMessage
+
+	if appendMode {
+		newEntries = append(newEntries, oldEntries...)
+	}
+
+	used := make([]bool, len(oldEntries))
+	added := 0
+
+	for _, author := range uniqueAuthors(authors) {
+		if author.Name == "" {
+			logs.Debugf("skip: <%s> %s: no name", author.Email, author.Login)
+			continue
+		}
+
+		found := false
+		for n, name := range oldNames {
+			if !used[n] && strings.EqualFold(name, joinName(author.Name)) {
+				used[n] = true
+				found = true
+				if !appendMode {
+					newEntries = append(newEntries, oldEntries[n])
+				}
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		logs.Infof("new: %s <%s> %s", author.Name, author.Email, author.Login)
+
+		newEntries = append(newEntries, marshalJSON(zenodoCreator{
+			Name: formatZenodoName(author.Name),
+		}))
+		added++
+	}
+
+	if added != 0 {
+		logs.Infof("added %d author(s)", added)
+	}
+
+	changed := len(newEntries) != len(oldEntries)
+	for n := 0; !changed && n < len(newEntries); n++ {
+		changed = !bytes.Equal(newEntries[n], oldEntries[n])
+	}
+
+	if newEntries == nil {
+		newEntries = []json.RawMessage{}
+	}
+	values[idx] = marshalJSON(newEntries)
+
+	return keys, values, changed, nil
+}
+
+// Format name as "Family, Given", as recommended by zenodo.
+func formatZenodoName(name string) string {
+	given, particle, family := splitName(name)
+
+	if given == "" {
+		return joinName(particle, family)
+	}
+
+	return joinName(particle, family) + ", " + given
+}
+
+// Convert "Family, Given" back to "Given Family".
+func parseZenodoName(name string) string {
+	if family, given, ok := strings.Cut(name, ","); ok {
+		return joinName(given, family)
+	}
+
+	return joinName(name)
+}
@@ -0,0 +1,18 @@
+package gen
+
+import (
+	"testing"
+)
+
+func TestUpdateZenodoErrors(t *testing.T) {
+	for _, data := range []string{
+		`[]`,
+		`{"creators": {}}`,
+		`{"creators": [1]}`,
+		`{"creators": [`,
+	} {
+		if _, err := updateZenodo([]byte(data), testStructuredAuthors, false); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}